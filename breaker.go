@@ -0,0 +1,239 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned instead of making a request while the Client's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open: the Flow API is failing")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen fails every request without making it.
+	BreakerOpen
+
+	// BreakerHalfOpen lets a few probe requests through to check if Flow recovered.
+	BreakerHalfOpen
+)
+
+// String returns the name of the state.
+func (bs BreakerState) String() string {
+	switch bs {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// BreakerSettings configures a CircuitBreaker.
+type BreakerSettings struct {
+	// FailureRatio is the ratio of failed requests in a Window that opens the breaker. It defaults to 0.5.
+	FailureRatio float64
+
+	// MinRequests is the number of requests needed in a Window before the breaker can open. It defaults to 10.
+	MinRequests int
+
+	// Window is the period over which the failures are counted. It defaults to one minute.
+	Window time.Duration
+
+	// OpenDuration is how long the breaker stays open before probing Flow again. It defaults to 30 seconds.
+	OpenDuration time.Duration
+
+	// HalfOpenProbes is the number of successful probes needed to close the breaker again, which is also the maximum
+	// number of concurrent probes. It defaults to 1.
+	HalfOpenProbes int
+
+	// OnStateChange is optionally set to be notified when the breaker changes its state.
+	OnStateChange func(from, to BreakerState)
+}
+
+// CircuitBreaker fails the requests to Flow fast while Flow is failing, instead of waiting on every one of them.
+// Network errors and server errors count as failures; error responses of invalid requests don't.
+type CircuitBreaker struct {
+	settings BreakerSettings
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+	generation  uint64
+}
+
+// NewCircuitBreaker creates a closed *CircuitBreaker.
+func NewCircuitBreaker(settings BreakerSettings) *CircuitBreaker {
+	if settings.FailureRatio <= 0 {
+		settings.FailureRatio = 0.5
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = 10
+	}
+	if settings.Window <= 0 {
+		settings.Window = time.Minute
+	}
+	if settings.OpenDuration <= 0 {
+		settings.OpenDuration = 30 * time.Second
+	}
+	if settings.HalfOpenProbes <= 0 {
+		settings.HalfOpenProbes = 1
+	}
+
+	return &CircuitBreaker{
+		settings:    settings,
+		windowStart: time.Now(),
+	}
+}
+
+// clone returns a closed *CircuitBreaker with the same settings, or nil if b is nil.
+func (b *CircuitBreaker) clone() *CircuitBreaker {
+	if b == nil {
+		return nil
+	}
+
+	return NewCircuitBreaker(b.settings)
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow reports whether a request can be made, along with the generation of the breaker's state it was allowed in.
+// Every allowed request must be followed by a call to record with that generation.
+func (b *CircuitBreaker) allow() (generation uint64, allowed bool) {
+	b.mu.Lock()
+	from := b.state
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.settings.OpenDuration {
+		b.setState(BreakerHalfOpen)
+	}
+
+	allowed = true
+	switch b.state {
+	case BreakerOpen:
+		allowed = false
+	case BreakerHalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			allowed = false
+		} else {
+			b.probes++
+		}
+	}
+
+	generation = b.generation
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return generation, allowed
+}
+
+// record reports the result of an allowed request. A nil failed means that the result says nothing about Flow's
+// health, like a request canceled by the caller. Results of requests allowed in an earlier generation are ignored, so
+// a slow request made before the breaker opened can't be taken for a probe.
+func (b *CircuitBreaker) record(generation uint64, failed *bool) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	from := b.state
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probes--
+		if failed == nil {
+			break
+		}
+
+		if *failed {
+			b.setState(BreakerOpen)
+		} else {
+			b.successes++
+			if b.successes >= b.settings.HalfOpenProbes {
+				b.setState(BreakerClosed)
+			}
+		}
+	case BreakerClosed:
+		if failed == nil {
+			break
+		}
+
+		if time.Since(b.windowStart) >= b.settings.Window {
+			b.windowStart = time.Now()
+			b.requests = 0
+			b.failures = 0
+		}
+
+		b.requests++
+		if *failed {
+			b.failures++
+		}
+
+		if b.requests >= b.settings.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio {
+			b.setState(BreakerOpen)
+		}
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// setState moves the breaker to a new state, starting a new generation and resetting its counters. It must be called
+// with the lock held.
+func (b *CircuitBreaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+	b.windowStart = time.Now()
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+
+	if state == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+}
+
+// notify calls OnStateChange if the state changed.
+func (b *CircuitBreaker) notify(from, to BreakerState) {
+	if from != to && b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(from, to)
+	}
+}
+
+// breakerResult classifies the result of a request for the circuit breaker. It returns nil if the result must not be
+// counted.
+func breakerResult(ctx context.Context, info requestInfo) *bool {
+	if info.Err != nil && ctx.Err() != nil {
+		return nil
+	}
+
+	failed := info.Err != nil && (info.StatusCode == 0 || info.StatusCode >= http.StatusInternalServerError)
+	return &failed
+}
@@ -0,0 +1,232 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestCircuitBreakerTransitions checks the state of the breaker along a sequence of requests.
+func TestCircuitBreakerTransitions(t *testing.T) {
+	const (
+		ok      = "ok"
+		failed  = "failed"
+		ignored = "ignored"
+		wait    = "wait"
+	)
+
+	type step struct {
+		result      string
+		wantAllowed bool
+		wantState   BreakerState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens on failure ratio",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerOpen},
+				{result: ok, wantAllowed: false, wantState: BreakerOpen},
+			},
+		},
+		{
+			name: "stays closed under the ratio",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "ignores canceled requests",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: ignored, wantAllowed: true, wantState: BreakerClosed},
+				{result: ignored, wantAllowed: true, wantState: BreakerClosed},
+				{result: ignored, wantAllowed: true, wantState: BreakerClosed},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "closes after a successful probe",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerOpen},
+				{result: wait},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "opens again after a failed probe",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerOpen},
+				{result: wait},
+				{result: failed, wantAllowed: true, wantState: BreakerOpen},
+				{result: ok, wantAllowed: false, wantState: BreakerOpen},
+			},
+		},
+		{
+			name: "probes again after a canceled probe",
+			steps: []step{
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerClosed},
+				{result: failed, wantAllowed: true, wantState: BreakerOpen},
+				{result: wait},
+				{result: ignored, wantAllowed: true, wantState: BreakerHalfOpen},
+				{result: ok, wantAllowed: true, wantState: BreakerClosed},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		b := NewCircuitBreaker(BreakerSettings{
+			MinRequests:  4,
+			FailureRatio: 0.5,
+			OpenDuration: 20 * time.Millisecond,
+		})
+
+		for i, step := range test.steps {
+			if step.result == wait {
+				time.Sleep(25 * time.Millisecond)
+				continue
+			}
+
+			generation, allowed := b.allow()
+			if allowed != step.wantAllowed {
+				t.Fatalf("%s: step %d: allow() = %v, want %v", test.name, i, allowed, step.wantAllowed)
+			}
+			if allowed {
+				var result *bool
+				if step.result != ignored {
+					result = boolPtr(step.result == failed)
+				}
+				b.record(generation, result)
+			}
+
+			if state := b.State(); state != step.wantState {
+				t.Fatalf("%s: step %d: State() = %v, want %v", test.name, i, state, step.wantState)
+			}
+		}
+	}
+}
+
+// TestCircuitBreakerProbes checks that the half-open breaker lets a single probe through, and that the result of a
+// request allowed before the breaker opened isn't taken for the probe's.
+func TestCircuitBreakerProbes(t *testing.T) {
+	var changes []BreakerState
+	b := NewCircuitBreaker(BreakerSettings{
+		MinRequests:  1,
+		OpenDuration: 20 * time.Millisecond,
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+
+	slow, _ := b.allow()
+	generation, _ := b.allow()
+	b.record(generation, boolPtr(true))
+	time.Sleep(25 * time.Millisecond)
+
+	probe, allowed := b.allow()
+	if !allowed {
+		t.Fatalf("allow() of the first probe = false, want true")
+	}
+	if _, allowed := b.allow(); allowed {
+		t.Fatalf("allow() of a second concurrent probe = true, want false")
+	}
+
+	b.record(slow, boolPtr(false))
+	if state := b.State(); state != BreakerHalfOpen {
+		t.Fatalf("State() after a stale result = %v, want %v", state, BreakerHalfOpen)
+	}
+
+	b.record(probe, boolPtr(false))
+	if state := b.State(); state != BreakerClosed {
+		t.Fatalf("State() after the probe succeeded = %v, want %v", state, BreakerClosed)
+	}
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("OnStateChange() called with %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+// TestCircuitBreakerBeforeRateLimits checks that requests fail fast while the breaker is open, instead of waiting for
+// the rate limits first.
+func TestCircuitBreakerBeforeRateLimits(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	c := flow.client()
+	c.Breaker = NewCircuitBreaker(BreakerSettings{MinRequests: 1, OpenDuration: time.Hour})
+	c.RateLimits = &RateLimits{Default: NewLimiter(0, 1, 1)}
+
+	generation, _ := c.Breaker.allow()
+	c.Breaker.record(generation, boolPtr(true))
+
+	// The only slot of the limiter is taken, so a request waiting for it would block until ctx is done.
+	_, _ = c.RateLimits.Default.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := c.GetOrderContext(ctx, "token")
+	if errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("GetOrderContext() = %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+// TestBreakerResult checks which results count as failures.
+func TestBreakerResult(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		info requestInfo
+		want *bool
+	}{
+		{name: "success", ctx: context.Background(), info: requestInfo{StatusCode: 200}, want: boolPtr(false)},
+		{name: "invalid request", ctx: context.Background(), info: requestInfo{StatusCode: 400, Err: &requestError{}},
+			want: boolPtr(false)},
+		{name: "server error", ctx: context.Background(), info: requestInfo{StatusCode: 503, Err: &requestError{}},
+			want: boolPtr(true)},
+		{name: "network error", ctx: context.Background(), info: requestInfo{Err: errors.New("connection refused")},
+			want: boolPtr(true)},
+		{name: "canceled", ctx: canceled, info: requestInfo{Err: context.Canceled}},
+	}
+
+	for _, test := range tests {
+		got := breakerResult(test.ctx, test.info)
+		if (got == nil) != (test.want == nil) || got != nil && *got != *test.want {
+			t.Errorf("%s: breakerResult() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// boolPtr returns a pointer to value.
+func boolPtr(value bool) *bool {
+	return &value
+}
@@ -0,0 +1,161 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// TestHTTPOrderConfirmationHandler checks the status answered by the error-returning handler, and that a delivery is
+// only acknowledged once the callback succeeds.
+func TestHTTPOrderConfirmationHandler(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	flow.setOrder("paid", &Order{FlowOrder: 1, Status: OrderStatusPayed})
+	flow.setOrder("rejected", &Order{FlowOrder: 2, Status: OrderStatusRejected})
+	flow.setOrder("in-flight", &Order{FlowOrder: 3, Status: OrderStatusPayed})
+
+	c := flow.client()
+	c.IdempotencyStore = NewMemoryIdempotencyStore()
+	_, _ = c.IdempotencyStore.Claim(orderKey(&Order{FlowOrder: 3, Status: OrderStatusPayed}), time.Hour)
+
+	calls := 0
+	fail := true
+	handler := c.HTTPOrderConfirmationHandler(func(_ context.Context, order *Order) error {
+		calls++
+		if fail {
+			return errors.New("unable to store the order")
+		}
+
+		return nil
+	})
+
+	tests := []struct {
+		name      string
+		body      string
+		fail      bool
+		want      int
+		wantCalls int
+	}{
+		{name: "no token", body: "", want: http.StatusBadRequest},
+		{name: "repeated token", body: "token=paid&token=paid", want: http.StatusBadRequest},
+		{name: "unknown order", body: "token=unknown", want: http.StatusInternalServerError},
+		{name: "unhandled status", body: "token=rejected", want: http.StatusUnauthorized},
+		{name: "in-flight delivery", body: "token=in-flight", want: http.StatusConflict},
+		{name: "failed callback", body: "token=paid", fail: true, want: http.StatusInternalServerError, wantCalls: 1},
+		{name: "retried delivery", body: "token=paid", want: http.StatusOK, wantCalls: 1},
+		{name: "delivered order", body: "token=paid", want: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		calls = 0
+		fail = test.fail
+
+		rq := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(test.body))
+		rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, rq)
+
+		if rec.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, rec.Code, test.want)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%s: callback called %d times, want %d", test.name, calls, test.wantCalls)
+		}
+	}
+}
+
+// fakeFlow is a Flow API server that answers the status of the orders and refunds set on it.
+type fakeFlow struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	orders  map[string]*Order
+	refunds map[string]*RefundStatus
+}
+
+// newFakeFlow starts an empty *fakeFlow, which the caller must close.
+func newFakeFlow() *fakeFlow {
+	f := &fakeFlow{
+		orders:  make(map[string]*Order),
+		refunds: make(map[string]*RefundStatus),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+
+	return f
+}
+
+// client returns a client that sends its requests to the server.
+func (f *fakeFlow) client() *Client {
+	c := NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY")
+	c.URL = f.URL
+
+	return c
+}
+
+// setOrder sets the order answered for a token.
+func (f *fakeFlow) setOrder(token string, order *Order) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.orders[token] = order
+}
+
+// setRefund sets the refund answered for a token.
+func (f *fakeFlow) setRefund(token string, refund *RefundStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refunds[token] = refund
+}
+
+// serve answers a request to the API.
+func (f *fakeFlow) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query, _ := url.ParseQuery(r.URL.RawQuery)
+
+	var result interface{}
+	switch r.URL.Path {
+	case "/payment/getStatus":
+		if order, ok := f.orders[query.Get("token")]; ok {
+			result = order
+		}
+	case "/payment/getStatusByCommerceId":
+		for _, order := range f.orders {
+			if order.CommerceOrder == query.Get("commerceId") {
+				result = order
+			}
+		}
+	case "/refund/getStatus":
+		if refund, ok := f.refunds[query.Get("token")]; ok {
+			result = refund
+		}
+	}
+
+	if result == nil {
+		f.write(w, http.StatusBadRequest, &requestError{Message: "not found", Code: 105})
+		return
+	}
+
+	f.write(w, http.StatusOK, result)
+}
+
+// write answers with a JSON body.
+func (f *fakeFlow) write(w http.ResponseWriter, status int, body interface{}) {
+	data, _ := jsoniter.Marshal(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
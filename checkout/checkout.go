@@ -0,0 +1,566 @@
+// Package checkout tracks the lifecycle of Flow orders: it creates them, stores them in a Repository, and moves them
+// through a validated state machine as confirmations and polling results arrive:
+//
+//	created -> awaiting -> payed / rejected / canceled / expired
+//	created -> failed
+//	payed -> refunding -> refunded
+//
+// Orders that Flow refuses to create are failed. Orders whose creation had an unknown outcome stay created, and can
+// move straight to their final state once Flow reports it. A refund that Flow rejects or cancels moves the order from
+// refunding back to payed. Each change of state is reported as an Event.
+package checkout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidTransition is returned when an order can't change to the requested state.
+	ErrInvalidTransition = errors.New("invalid state transition")
+
+	// ErrOrderStoreRequired is returned by New when the Repository isn't a *MemoryRepository and no OrderStore is set.
+	ErrOrderStoreRequired = errors.New("an order store is required with a persistent repository")
+)
+
+// Event is a change of state of an order.
+type Event struct {
+	// From is the previous state.
+	From State
+
+	// To is the new state.
+	To State
+
+	// Record is the order, already in the new state.
+	Record Record
+}
+
+// Options configures a Checkout.
+type Options struct {
+	// OnEvent is optionally set to be called on every change of state, once it's stored and outside of any lock, so
+	// it can call the Checkout back. The record is marked with EventPending until it succeeds: if it fails, the error
+	// is returned so the confirmation is retried, and the retry delivers the event again. It might be called more than
+	// once for the same change.
+	OnEvent func(ctx context.Context, event Event) error
+
+	// OrderStore remembers the orders created in Flow, so Start never creates two orders for the same commerce order.
+	// It must be as persistent as the Repository: it's only optional with a *MemoryRepository, and defaults to a
+	// flow.MemoryOrderStore.
+	OrderStore flow.OrderStore
+
+	// OnInvalidTransition is optionally set to be called when Flow reports a status the order can't change to, like a
+	// payment received after the order expired. Those reports are otherwise ignored.
+	OnInvalidTransition func(ctx context.Context, record Record, to State)
+}
+
+// Checkout creates orders and tracks their lifecycle. The changes of each order are serialized, so a confirmation and
+// a polling result for the same order never race, while the changes of different orders run concurrently.
+type Checkout struct {
+	client *flow.Client
+	repo   Repository
+	opts   Options
+
+	locks    orderLocks
+	starting orderLocks
+}
+
+// New creates a *Checkout that creates orders with the client and stores them in repo. It fails with
+// ErrOrderStoreRequired if repo is persistent and opts has no OrderStore, as the reservations of a memory store would
+// be lost on restart.
+func New(c *flow.Client, repo Repository, opts Options) (*Checkout, error) {
+	if opts.OrderStore == nil {
+		if _, ok := repo.(*MemoryRepository); !ok {
+			return nil, ErrOrderStoreRequired
+		}
+
+		opts.OrderStore = flow.NewMemoryOrderStore()
+	}
+
+	return &Checkout{
+		client: c,
+		repo:   repo,
+		opts:   opts,
+	}, nil
+}
+
+// Start stores a new order in the created state, creates it in Flow with Client.CreateOrderIdempotentContext and
+// moves it to the awaiting state. Starting an order that is already awaiting returns its record without creating it
+// again.
+//
+// If Flow refuses to create the order, it moves to the failed state. If the creation fails in a way that doesn't tell
+// whether Flow created the order, it stays in the created state, is checked by the Poller, and Start can be called
+// again to resume it: if the order's reservation is still held in the OrderStore, it's looked up in Flow, and either
+// recovered or released and created again. If Flow created the order but its token was lost, the order moves to the
+// awaiting state without a PaymentURL, and the *flow.RecoveredOrderError is returned along with the record.
+//
+// Starts of the same order are serialized within a process, but must not run concurrently in several processes.
+func (co *Checkout) Start(ctx context.Context, or flow.OrderRequest) (*Record, error) {
+	unlock := co.starting.lock(or.CommerceOrder)
+	defer unlock()
+
+	now := time.Now()
+	resumed := false
+	err := co.repo.Create(Record{
+		CommerceOrder: or.CommerceOrder,
+		State:         StateCreated,
+		Request:       or,
+		Created:       now,
+		Updated:       now,
+	})
+	if err == ErrExists {
+		existing, err := co.repo.Get(or.CommerceOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		switch existing.State {
+		case StateCreated:
+			or = existing.Request
+			resumed = true
+		case StateAwaiting:
+			return co.emit(ctx, existing)
+		default:
+			return nil, ErrExists
+		}
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to store order")
+	}
+
+	result, createErr := co.client.CreateOrderIdempotentContext(ctx, or, co.opts.OrderStore)
+	if createErr == flow.ErrOrderInFlight && resumed {
+		result, createErr = co.resume(ctx, or)
+	}
+
+	recovered, ok := createErr.(*flow.RecoveredOrderError)
+	if createErr != nil && !ok {
+		if !flow.IsAmbiguous(createErr) && errors.Cause(createErr) != flow.ErrCircuitOpen {
+			_, err = co.transition(ctx, or.CommerceOrder, StateFailed, nil, StateCreated)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, createErr
+	}
+
+	record, err := co.transition(ctx, or.CommerceOrder, StateAwaiting, func(record *Record) {
+		if ok {
+			record.FlowOrder = recovered.FlowID
+			return
+		}
+
+		record.FlowOrder = result.FlowID
+		record.Token = result.Token
+		record.PaymentURL = result.GetPaymentURL()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return record, createErr
+}
+
+// resume creates an order whose reservation was left in the OrderStore by an earlier Start with an unknown outcome.
+// The order is looked up in Flow: if it exists it's recovered, and if Flow answers that it doesn't, the reservation is
+// released and the order is created again.
+func (co *Checkout) resume(ctx context.Context, or flow.OrderRequest) (*flow.OrderResponse, error) {
+	order, err := co.client.GetOrderByCommerceIDContext(ctx, or.CommerceOrder)
+	if err == nil {
+		err = co.opts.OrderStore.Save(or.CommerceOrder, &flow.OrderResponse{FlowID: order.FlowOrder})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to save order to the store")
+		}
+
+		return nil, &flow.RecoveredOrderError{FlowID: order.FlowOrder}
+	}
+
+	if !co.client.IsOrderNotFound(err) {
+		return nil, flow.ErrOrderOutcomeUnknown
+	}
+
+	err = co.opts.OrderStore.Release(or.CommerceOrder)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to release order in the store")
+	}
+
+	return co.client.CreateOrderIdempotentContext(ctx, or, co.opts.OrderStore)
+}
+
+// Get returns the record of a commerce order.
+func (co *Checkout) Get(commerceOrder string) (*Record, error) {
+	return co.repo.Get(commerceOrder)
+}
+
+// Refund requests the refund of a paid order, which moves to the refunding state before Flow is contacted, so the
+// same order can't be refunded twice. The order moves to the refunded state once Flow confirms the refund through
+// the RefundCallbacks, or back to payed if Flow rejects it. The OrderID of the refund defaults to the commerce order,
+// and is stored as the RefundOrderID of the record before Flow is contacted.
+//
+// If the request fails in a way that doesn't tell whether Flow created the refund, the order stays refunding without
+// a RefundToken. Find the refund by its RefundOrderID in Flow, and resolve it with ResolveRefund. The event of the
+// refunding state is emitted once Flow is contacted.
+func (co *Checkout) Refund(ctx context.Context, commerceOrder string, refund flow.Refund) (*flow.RefundStatus, error) {
+	if refund.OrderID == "" {
+		refund.OrderID = commerceOrder
+	}
+
+	record, err := co.change(commerceOrder, StateRefunding, func(record *Record) {
+		record.RefundOrderID = refund.OrderID
+		record.RefundToken = ""
+	}, StatePayed)
+	if err != nil {
+		return nil, err
+	}
+
+	status, refundErr := co.client.CreateRefundContext(ctx, refund)
+	if refundErr != nil {
+		if !flow.IsAmbiguous(refundErr) {
+			_, err = co.transition(ctx, commerceOrder, StatePayed, nil, StateRefunding)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, refundErr
+	}
+
+	err = co.update(commerceOrder, func(record *Record) bool {
+		record.RefundToken = status.Token
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record.RefundToken = status.Token
+	_, err = co.emit(ctx, record)
+	return status, err
+}
+
+// ResolveRefund resolves a refunding order whose refund had an unknown outcome, once it was checked in Flow. If Flow
+// created the refund, refundToken is its token: it's stored, and the order moves to the state matching the refund's
+// current status. If Flow didn't create the refund, refundToken is empty and the order moves back to payed, so it can
+// be refunded again.
+func (co *Checkout) ResolveRefund(ctx context.Context, commerceOrder, refundToken string) (*Record, error) {
+	if refundToken == "" {
+		return co.transition(ctx, commerceOrder, StatePayed, nil, StateRefunding)
+	}
+
+	status, err := co.client.GetRefundStatusContext(ctx, refundToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *Record
+	err = co.update(commerceOrder, func(current *Record) bool {
+		record = current
+		if current.State != StateRefunding || current.RefundToken != "" {
+			return false
+		}
+
+		current.RefundToken = refundToken
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record.RefundToken != refundToken {
+		return nil, errors.Wrapf(ErrInvalidTransition, "order %s has no refund to resolve", commerceOrder)
+	}
+
+	switch status.Status {
+	case flow.RefundStatusRefunded:
+		return co.transition(ctx, commerceOrder, StateRefunded, nil, StateRefunding, StateRefunded)
+	case flow.RefundStatusRejected, flow.RefundStatusCanceled:
+		return co.transition(ctx, commerceOrder, StatePayed, nil, StateRefunding, StatePayed)
+	}
+
+	return co.emit(ctx, record)
+}
+
+// OrderCallbacks returns the callbacks that move the orders to the state reported by Flow, to be served with
+// Client.HTTPOrderCallbacks or any other confirmation handler.
+func (co *Checkout) OrderCallbacks() flow.OrderCallbacks {
+	fn := func(ctx context.Context, order *flow.Order) error {
+		to, ok := stateForStatus(order.Status)
+		if !ok {
+			return nil
+		}
+
+		return co.apply(ctx, order.CommerceOrder, to, func(record *Record) {
+			if record.FlowOrder == 0 {
+				record.FlowOrder = order.FlowOrder
+			}
+		}, StateCreated, StateAwaiting, to)
+	}
+
+	return flow.OrderCallbacks{
+		OnPaid:     fn,
+		OnRejected: fn,
+		OnCanceled: fn,
+	}
+}
+
+// RefundCallbacks returns the callbacks that move the orders to the refunded state once their refund is completed,
+// or back to payed if it's rejected or canceled, to be served with Client.HTTPRefundCallbacks or any other
+// confirmation handler. Refunds whose token isn't stored yet fail, so Flow retries the confirmation once Refund or
+// ResolveRefund has stored it.
+func (co *Checkout) RefundCallbacks() flow.RefundCallbacks {
+	apply := func(to State, from ...State) flow.RefundHandlerFunc {
+		return func(ctx context.Context, refund *flow.RefundStatus) error {
+			record, err := co.repo.GetByRefundToken(refund.Token)
+			if err != nil {
+				return errors.Wrapf(err, "unable to find the order of refund %s", refund.Token)
+			}
+
+			return co.apply(ctx, record.CommerceOrder, to, nil, from...)
+		}
+	}
+
+	return flow.RefundCallbacks{
+		OnRefunded: apply(StateRefunded, StateRefunding, StateRefunded),
+		OnRejected: apply(StatePayed, StateRefunding, StatePayed),
+		OnCanceled: apply(StatePayed, StateRefunding, StatePayed),
+	}
+}
+
+// Poller returns a *flow.Poller that checks the created and awaiting orders of the repository, moving them to their
+// final state, or to the expired state once their TimeoutSeconds elapsed. The created orders are looked up by their
+// commerce order. The OnExpired option is replaced.
+func (co *Checkout) Poller(opts flow.PollerOptions) *flow.Poller {
+	opts.OnExpired = func(ctx context.Context, order flow.PendingOrder) error {
+		return co.apply(ctx, order.CommerceOrder, StateExpired, nil, StateCreated, StateAwaiting, StateExpired)
+	}
+
+	return flow.NewPoller(co.client, pendingStore{co}, co.OrderCallbacks(), opts)
+}
+
+// apply moves an order in one of the from states to a state reported by Flow, applying update to its record. Reports of
+// the current state are ignored, and reports of states the order can't change to are passed to OnInvalidTransition.
+func (co *Checkout) apply(ctx context.Context, commerceOrder string, to State, update func(*Record),
+	from ...State) error {
+	_, err := co.transition(ctx, commerceOrder, to, update, from...)
+	if errors.Cause(err) == ErrNotFound {
+		return nil
+	}
+	if errors.Cause(err) != ErrInvalidTransition {
+		return err
+	}
+
+	if co.opts.OnInvalidTransition != nil {
+		record, err := co.repo.Get(commerceOrder)
+		if err != nil {
+			return err
+		}
+
+		co.opts.OnInvalidTransition(ctx, *record, to)
+	}
+
+	return nil
+}
+
+// transition moves an order to a state, applying update to its record, storing it and then emitting the event. Moving
+// an order to the state it's already in does nothing, unless the allowed states the order must be in are given, but
+// emits the event of the last change again if it's still pending.
+func (co *Checkout) transition(ctx context.Context, commerceOrder string, to State, update func(*Record),
+	allowed ...State) (*Record, error) {
+	record, err := co.change(commerceOrder, to, update, allowed...)
+	if err != nil {
+		return nil, err
+	}
+
+	return co.emit(ctx, record)
+}
+
+// emit delivers the pending event of a record to OnEvent, and then clears its EventPending mark.
+func (co *Checkout) emit(ctx context.Context, record *Record) (*Record, error) {
+	if !record.EventPending || co.opts.OnEvent == nil {
+		return record, nil
+	}
+
+	err := co.opts.OnEvent(ctx, Event{From: record.PreviousState, To: record.State, Record: *record})
+	if err != nil {
+		return nil, err
+	}
+
+	err = co.update(record.CommerceOrder, func(current *Record) bool {
+		if current.State != record.State || !current.EventPending {
+			return false
+		}
+
+		current.EventPending = false
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record.EventPending = false
+	return record, nil
+}
+
+// change moves an order to a state and stores it while holding the order's lock, marking its event as pending if
+// there's an OnEvent to deliver it to.
+func (co *Checkout) change(commerceOrder string, to State, update func(*Record), allowed ...State) (*Record, error) {
+	unlock := co.locks.lock(commerceOrder)
+	defer unlock()
+
+	record, err := co.repo.Get(commerceOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	from := record.State
+	if len(allowed) > 0 && !contains(allowed, from) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "order %s can't change from %s to %s", commerceOrder, from, to)
+	}
+
+	if from == to {
+		return record, nil
+	}
+
+	if !from.CanTransition(to) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "order %s can't change from %s to %s", commerceOrder, from, to)
+	}
+
+	record.PreviousState = from
+	record.State = to
+	record.EventPending = co.opts.OnEvent != nil
+	record.Updated = time.Now()
+	if update != nil {
+		update(record)
+	}
+
+	err = co.repo.Update(*record)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to store order")
+	}
+
+	return record, nil
+}
+
+// update changes the record of an order without changing its state, while holding the order's lock. The record is
+// only stored if fn returns true.
+func (co *Checkout) update(commerceOrder string, fn func(record *Record) bool) error {
+	unlock := co.locks.lock(commerceOrder)
+	defer unlock()
+
+	record, err := co.repo.Get(commerceOrder)
+	if err != nil {
+		return err
+	}
+
+	if !fn(record) {
+		return nil
+	}
+
+	err = co.repo.Update(*record)
+	if err != nil {
+		return errors.Wrap(err, "unable to store order")
+	}
+
+	return nil
+}
+
+// contains reports whether states contains state.
+func contains(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pendingStore is a flow.PendingOrderStore over the created and awaiting orders of a Checkout.
+type pendingStore struct {
+	co *Checkout
+}
+
+// Pending returns the created and awaiting orders.
+func (ps pendingStore) Pending() ([]flow.PendingOrder, error) {
+	var orders []flow.PendingOrder
+	for _, state := range []State{StateCreated, StateAwaiting} {
+		records, err := ps.co.repo.ListByState(state)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			orders = append(orders, flow.PendingOrder{
+				Token:          record.Token,
+				CommerceOrder:  record.CommerceOrder,
+				Created:        record.Created,
+				TimeoutSeconds: record.Request.TimeoutSeconds,
+				Attempts:       record.PollAttempts,
+				NextCheck:      record.NextPoll,
+			})
+		}
+	}
+
+	return orders, nil
+}
+
+// Update saves the polling schedule of an order, if it's still pending.
+func (ps pendingStore) Update(order flow.PendingOrder) error {
+	return ps.co.update(order.CommerceOrder, func(record *Record) bool {
+		if record.State != StateCreated && record.State != StateAwaiting {
+			return false
+		}
+
+		record.PollAttempts = order.Attempts
+		record.NextPoll = order.NextCheck
+		return true
+	})
+}
+
+// Remove does nothing, as the orders stop being pending when they leave the created and awaiting states.
+func (ps pendingStore) Remove(flow.PendingOrder) error {
+	return nil
+}
+
+// orderLocks serializes the changes of each order, without blocking the changes of other orders. The zero value is
+// ready to use.
+type orderLocks struct {
+	mu    sync.Mutex
+	locks map[string]*orderLock
+}
+
+// orderLock is the lock of an order, along with the number of callers holding or waiting for it.
+type orderLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// lock locks the order, and returns the function that unlocks it.
+func (ol *orderLocks) lock(commerceOrder string) (unlock func()) {
+	ol.mu.Lock()
+	if ol.locks == nil {
+		ol.locks = make(map[string]*orderLock)
+	}
+
+	l, set := ol.locks[commerceOrder]
+	if !set {
+		l = &orderLock{}
+		ol.locks[commerceOrder] = l
+	}
+	l.users++
+	ol.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		ol.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(ol.locks, commerceOrder)
+		}
+		ol.mu.Unlock()
+	}
+}
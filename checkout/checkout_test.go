@@ -0,0 +1,601 @@
+package checkout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// orderNotFoundCode is the code the fake Flow API answers for orders that don't exist.
+const orderNotFoundCode = 105
+
+var (
+	// created is the answer of Flow to a created order.
+	created = response{status: http.StatusOK, body: flow.OrderResponse{FlowID: 10, Token: "token",
+		URL: "https://flow.test/pay"}}
+
+	// found is the answer of Flow to the lookup of an existing order.
+	found = response{status: http.StatusOK, body: flow.Order{FlowOrder: 10, CommerceOrder: "order-1"}}
+
+	// notFound is the answer of Flow to the lookup of an order that doesn't exist.
+	notFound = response{status: http.StatusBadRequest, body: map[string]interface{}{"code": orderNotFoundCode,
+		"message": "order not found"}}
+
+	// refused is the answer of Flow to an invalid request.
+	refused = response{status: http.StatusBadRequest, body: map[string]interface{}{"code": 1, "message": "invalid"}}
+
+	// failed is the answer of Flow when it fails, which doesn't tell whether the request was applied.
+	failed = response{status: http.StatusInternalServerError, body: map[string]interface{}{"code": 1,
+		"message": "internal error"}}
+)
+
+// TestStart checks the state of an order after each attempt to start it, depending on Flow's answers.
+func TestStart(t *testing.T) {
+	type attempt struct {
+		create    response
+		lookup    response
+		wantState State
+		wantErr   bool
+	}
+
+	tests := []struct {
+		name        string
+		attempts    []attempt
+		wantCreates int
+		wantToken   string
+	}{
+		{
+			name:        "created",
+			attempts:    []attempt{{create: created, wantState: StateAwaiting}},
+			wantCreates: 1,
+			wantToken:   "token",
+		},
+		{
+			name: "started twice",
+			attempts: []attempt{
+				{create: created, wantState: StateAwaiting},
+				{create: created, wantState: StateAwaiting},
+			},
+			wantCreates: 1,
+			wantToken:   "token",
+		},
+		{
+			name: "refused",
+			attempts: []attempt{
+				{create: refused, wantState: StateFailed, wantErr: true},
+				{create: created, wantState: StateFailed, wantErr: true},
+			},
+			wantCreates: 1,
+		},
+		{
+			name: "unknown outcome of an order not created",
+			attempts: []attempt{
+				{create: failed, lookup: notFound, wantState: StateCreated, wantErr: true},
+				{create: created, wantState: StateAwaiting},
+			},
+			wantCreates: 2,
+			wantToken:   "token",
+		},
+		{
+			name: "unknown outcome of a created order",
+			attempts: []attempt{
+				{create: failed, lookup: found, wantState: StateAwaiting, wantErr: true},
+			},
+			wantCreates: 1,
+		},
+		{
+			name: "failed lookup of an order not created",
+			attempts: []attempt{
+				{create: failed, lookup: failed, wantState: StateCreated, wantErr: true},
+				{create: created, lookup: notFound, wantState: StateAwaiting},
+			},
+			wantCreates: 2,
+			wantToken:   "token",
+		},
+		{
+			name: "failed lookup of a created order",
+			attempts: []attempt{
+				{create: failed, lookup: failed, wantState: StateCreated, wantErr: true},
+				{create: created, lookup: found, wantState: StateAwaiting, wantErr: true},
+			},
+			wantCreates: 1,
+		},
+		{
+			name: "failed lookups",
+			attempts: []attempt{
+				{create: failed, lookup: failed, wantState: StateCreated, wantErr: true},
+				{create: created, lookup: failed, wantState: StateCreated, wantErr: true},
+			},
+			wantCreates: 1,
+		},
+	}
+
+	for _, test := range tests {
+		api := newFakeFlow()
+		co := newCheckout(t, api, Options{})
+
+		for i, attempt := range test.attempts {
+			api.set("/payment/create", attempt.create)
+			api.set("/payment/getStatusByCommerceId", attempt.lookup)
+
+			_, err := co.Start(context.Background(), orderRequest())
+			if (err != nil) != attempt.wantErr {
+				t.Errorf("%s: attempt %d: Start() error = %v, want error %v", test.name, i, err, attempt.wantErr)
+			}
+
+			record, err := co.Get("order-1")
+			if err != nil {
+				t.Fatalf("%s: attempt %d: Get() failed: %v", test.name, i, err)
+			}
+			if record.State != attempt.wantState {
+				t.Errorf("%s: attempt %d: state = %s, want %s", test.name, i, record.State, attempt.wantState)
+			}
+		}
+
+		record, _ := co.Get("order-1")
+		if record.Token != test.wantToken {
+			t.Errorf("%s: token = %q, want %q", test.name, record.Token, test.wantToken)
+		}
+		if creates := api.calls("/payment/create"); creates != test.wantCreates {
+			t.Errorf("%s: %d orders created, want %d", test.name, creates, test.wantCreates)
+		}
+
+		api.Close()
+	}
+}
+
+// TestStartRecovered checks that an order created by Flow whose token was lost moves to the awaiting state, and that
+// the *flow.RecoveredOrderError is returned along with its record.
+func TestStartRecovered(t *testing.T) {
+	api := newFakeFlow()
+	defer api.Close()
+
+	api.set("/payment/create", failed)
+	api.set("/payment/getStatusByCommerceId", found)
+
+	co := newCheckout(t, api, Options{})
+	record, err := co.Start(context.Background(), orderRequest())
+
+	recovered, ok := err.(*flow.RecoveredOrderError)
+	if !ok {
+		t.Fatalf("Start() error = %v, want a *flow.RecoveredOrderError", err)
+	}
+	if record == nil || record.FlowOrder != recovered.FlowID || record.PaymentURL != "" {
+		t.Fatalf("Start() = %+v, want the awaiting record of flow order %d", record, recovered.FlowID)
+	}
+}
+
+// TestEvents checks that the events are delivered after each change is stored, and delivered again by the next
+// report of the same change if OnEvent failed.
+func TestEvents(t *testing.T) {
+	api := newFakeFlow()
+	defer api.Close()
+
+	api.set("/payment/create", created)
+
+	var events []Event
+	fail := false
+	co := newCheckout(t, api, Options{
+		OnEvent: func(ctx context.Context, event Event) error {
+			if fail {
+				return errors.New("unable to publish event")
+			}
+
+			events = append(events, event)
+			return nil
+		},
+	})
+
+	_, err := co.Start(context.Background(), orderRequest())
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	paid := co.OrderCallbacks().OnPaid
+	order := &flow.Order{FlowOrder: 10, CommerceOrder: "order-1", Status: flow.OrderStatusPayed}
+
+	fail = true
+	if err := paid(context.Background(), order); err == nil {
+		t.Fatalf("OnPaid() with a failing OnEvent = nil, want an error so the confirmation is retried")
+	}
+
+	record, _ := co.Get("order-1")
+	if record.State != StatePayed || !record.EventPending {
+		t.Fatalf("record after the event failed = %s pending %v, want %s pending true", record.State,
+			record.EventPending, StatePayed)
+	}
+
+	fail = false
+	if err := paid(context.Background(), order); err != nil {
+		t.Fatalf("OnPaid() failed: %v", err)
+	}
+	if err := paid(context.Background(), order); err != nil {
+		t.Fatalf("OnPaid() of a delivered event failed: %v", err)
+	}
+
+	want := []Event{
+		{From: StateCreated, To: StateAwaiting},
+		{From: StateAwaiting, To: StatePayed},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i].From != want[i].From || events[i].To != want[i].To || events[i].Record.State != want[i].To {
+			t.Errorf("event %d = %s -> %s, want %s -> %s", i, events[i].From, events[i].To, want[i].From, want[i].To)
+		}
+	}
+
+	record, _ = co.Get("order-1")
+	if record.EventPending {
+		t.Fatalf("record still has a pending event once it was delivered")
+	}
+}
+
+// TestOrderCallbacks checks the state of an order after each report of Flow.
+func TestOrderCallbacks(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int
+		expired     bool
+		wantState   State
+		wantInvalid State
+	}{
+		{name: "paid", statuses: []int{flow.OrderStatusPayed}, wantState: StatePayed},
+		{name: "rejected", statuses: []int{flow.OrderStatusRejected}, wantState: StateRejected},
+		{name: "canceled", statuses: []int{flow.OrderStatusCanceled}, wantState: StateCanceled},
+		{name: "paid twice", statuses: []int{flow.OrderStatusPayed, flow.OrderStatusPayed}, wantState: StatePayed},
+		{
+			name:        "rejected after paid",
+			statuses:    []int{flow.OrderStatusPayed, flow.OrderStatusRejected},
+			wantState:   StatePayed,
+			wantInvalid: StateRejected,
+		},
+		{
+			name:        "paid after expired",
+			statuses:    []int{flow.OrderStatusPayed},
+			expired:     true,
+			wantState:   StateExpired,
+			wantInvalid: StatePayed,
+		},
+	}
+
+	for _, test := range tests {
+		api := newFakeFlow()
+		api.set("/payment/create", created)
+
+		var invalid State
+		co := newCheckout(t, api, Options{
+			OnInvalidTransition: func(ctx context.Context, record Record, to State) {
+				invalid = to
+			},
+		})
+
+		_, err := co.Start(context.Background(), orderRequest())
+		if err != nil {
+			t.Fatalf("%s: Start() failed: %v", test.name, err)
+		}
+
+		if test.expired {
+			_, err = co.transition(context.Background(), "order-1", StateExpired, nil)
+			if err != nil {
+				t.Fatalf("%s: unable to expire order: %v", test.name, err)
+			}
+		}
+
+		callbacks := co.OrderCallbacks()
+		for _, status := range test.statuses {
+			fn := map[int]flow.OrderHandlerFunc{
+				flow.OrderStatusPayed:    callbacks.OnPaid,
+				flow.OrderStatusRejected: callbacks.OnRejected,
+				flow.OrderStatusCanceled: callbacks.OnCanceled,
+			}[status]
+
+			err = fn(context.Background(), &flow.Order{FlowOrder: 10, CommerceOrder: "order-1", Status: status})
+			if err != nil {
+				t.Fatalf("%s: callback of status %d failed: %v", test.name, status, err)
+			}
+		}
+
+		record, _ := co.Get("order-1")
+		if record.State != test.wantState {
+			t.Errorf("%s: state = %s, want %s", test.name, record.State, test.wantState)
+		}
+		if invalid != test.wantInvalid {
+			t.Errorf("%s: invalid transition to %q, want %q", test.name, invalid, test.wantInvalid)
+		}
+
+		api.Close()
+	}
+}
+
+// TestRefund checks the state of a paid order after its refund is requested and resolved.
+func TestRefund(t *testing.T) {
+	refundCreated := response{status: http.StatusOK, body: flow.RefundStatus{Token: "refund-token",
+		Status: flow.RefundStatusCreated}}
+	refunded := response{status: http.StatusOK, body: flow.RefundStatus{Token: "refund-token",
+		Status: flow.RefundStatusRefunded}}
+
+	tests := []struct {
+		name      string
+		create    response
+		resolve   *string
+		status    response
+		wantErr   bool
+		wantState State
+		wantToken string
+	}{
+		{
+			name:      "requested",
+			create:    refundCreated,
+			wantState: StateRefunding,
+			wantToken: "refund-token",
+		},
+		{
+			name:      "refused",
+			create:    refused,
+			wantErr:   true,
+			wantState: StatePayed,
+		},
+		{
+			name:      "unknown outcome",
+			create:    failed,
+			wantErr:   true,
+			wantState: StateRefunding,
+		},
+		{
+			name:      "unknown outcome of a refund not created",
+			create:    failed,
+			resolve:   stringPtr(""),
+			wantErr:   true,
+			wantState: StatePayed,
+		},
+		{
+			name:      "unknown outcome of a created refund",
+			create:    failed,
+			resolve:   stringPtr("refund-token"),
+			status:    refundCreated,
+			wantErr:   true,
+			wantState: StateRefunding,
+			wantToken: "refund-token",
+		},
+		{
+			name:      "unknown outcome of a completed refund",
+			create:    failed,
+			resolve:   stringPtr("refund-token"),
+			status:    refunded,
+			wantErr:   true,
+			wantState: StateRefunded,
+			wantToken: "refund-token",
+		},
+	}
+
+	for _, test := range tests {
+		api := newFakeFlow()
+		co := newCheckout(t, api, Options{})
+		startPaid(t, co, api)
+
+		api.set("/refund/create", test.create)
+		api.set("/refund/getStatus", test.status)
+
+		_, err := co.Refund(context.Background(), "order-1", flow.Refund{Amount: 1000})
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: Refund() error = %v, want error %v", test.name, err, test.wantErr)
+		}
+
+		if test.resolve != nil {
+			_, err = co.ResolveRefund(context.Background(), "order-1", *test.resolve)
+			if err != nil {
+				t.Fatalf("%s: ResolveRefund() failed: %v", test.name, err)
+			}
+		}
+
+		record, _ := co.Get("order-1")
+		if record.State != test.wantState {
+			t.Errorf("%s: state = %s, want %s", test.name, record.State, test.wantState)
+		}
+		if record.RefundOrderID != "order-1" {
+			t.Errorf("%s: refund order = %q, want %q", test.name, record.RefundOrderID, "order-1")
+		}
+		if record.RefundToken != test.wantToken {
+			t.Errorf("%s: refund token = %q, want %q", test.name, record.RefundToken, test.wantToken)
+		}
+
+		api.Close()
+	}
+}
+
+// TestRefundOnce checks that an order can't be refunded again while its refund is pending, and that the refund
+// confirmation completes it.
+func TestRefundOnce(t *testing.T) {
+	api := newFakeFlow()
+	defer api.Close()
+
+	co := newCheckout(t, api, Options{})
+	startPaid(t, co, api)
+
+	api.set("/refund/create", response{status: http.StatusOK, body: flow.RefundStatus{Token: "refund-token",
+		Status: flow.RefundStatusCreated}})
+
+	_, err := co.Refund(context.Background(), "order-1", flow.Refund{Amount: 1000})
+	if err != nil {
+		t.Fatalf("Refund() failed: %v", err)
+	}
+
+	_, err = co.Refund(context.Background(), "order-1", flow.Refund{Amount: 1000})
+	if errors.Cause(err) != ErrInvalidTransition {
+		t.Fatalf("Refund() of a refunding order = %v, want %v", err, ErrInvalidTransition)
+	}
+	if refunds := api.calls("/refund/create"); refunds != 1 {
+		t.Fatalf("%d refunds requested, want 1", refunds)
+	}
+
+	err = co.RefundCallbacks().OnRefunded(context.Background(), &flow.RefundStatus{Token: "refund-token",
+		Status: flow.RefundStatusRefunded})
+	if err != nil {
+		t.Fatalf("OnRefunded() failed: %v", err)
+	}
+
+	record, _ := co.Get("order-1")
+	if record.State != StateRefunded {
+		t.Fatalf("state = %s, want %s", record.State, StateRefunded)
+	}
+
+	_, err = co.ResolveRefund(context.Background(), "order-1", "")
+	if errors.Cause(err) != ErrInvalidTransition {
+		t.Fatalf("ResolveRefund() of a refunded order = %v, want %v", err, ErrInvalidTransition)
+	}
+}
+
+// TestNewRequiresOrderStore checks that a persistent repository can't be used with the default memory OrderStore.
+func TestNewRequiresOrderStore(t *testing.T) {
+	c := flow.NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY")
+
+	_, err := New(c, persistentRepository{NewMemoryRepository()}, Options{})
+	if err != ErrOrderStoreRequired {
+		t.Fatalf("New() = %v, want %v", err, ErrOrderStoreRequired)
+	}
+
+	_, err = New(c, persistentRepository{NewMemoryRepository()}, Options{OrderStore: flow.NewMemoryOrderStore()})
+	if err != nil {
+		t.Fatalf("New() with an OrderStore failed: %v", err)
+	}
+}
+
+// TestOrderLocks checks that the lock of an order is removed once nobody holds it.
+func TestOrderLocks(t *testing.T) {
+	var locks orderLocks
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := locks.lock("order-1")
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(locks.locks) != 0 {
+		t.Fatalf("%d locks kept, want 0", len(locks.locks))
+	}
+}
+
+// persistentRepository is a Repository that isn't a *MemoryRepository.
+type persistentRepository struct {
+	*MemoryRepository
+}
+
+// newCheckout creates a *Checkout with a memory repository and a client of the API.
+func newCheckout(t *testing.T, api *fakeFlow, opts Options) *Checkout {
+	t.Helper()
+
+	c := flow.NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY")
+	c.URL = api.URL
+	c.OrderNotFoundCode = orderNotFoundCode
+
+	co, err := New(c, NewMemoryRepository(), opts)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	return co
+}
+
+// startPaid starts the order-1 order and confirms its payment.
+func startPaid(t *testing.T, co *Checkout, api *fakeFlow) {
+	t.Helper()
+
+	api.set("/payment/create", created)
+	_, err := co.Start(context.Background(), orderRequest())
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	err = co.OrderCallbacks().OnPaid(context.Background(), &flow.Order{FlowOrder: 10, CommerceOrder: "order-1",
+		Status: flow.OrderStatusPayed})
+	if err != nil {
+		t.Fatalf("OnPaid() failed: %v", err)
+	}
+}
+
+// orderRequest returns the request of the order-1 order.
+func orderRequest() flow.OrderRequest {
+	return flow.OrderRequest{
+		CommerceOrder: "order-1",
+		Subject:       "Test order",
+		Amount:        1000,
+		PayerEmail:    "payer@example.com",
+	}
+}
+
+// stringPtr returns a pointer to value.
+func stringPtr(value string) *string {
+	return &value
+}
+
+// response is the answer of the fake Flow API to an endpoint.
+type response struct {
+	status int
+	body   interface{}
+}
+
+// fakeFlow is a Flow API server that answers each endpoint with the response set for it, and counts its calls.
+type fakeFlow struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]response
+	counts    map[string]int
+}
+
+// newFakeFlow starts a *fakeFlow that answers every endpoint with a server error, which the caller must close.
+func newFakeFlow() *fakeFlow {
+	f := &fakeFlow{
+		responses: make(map[string]response),
+		counts:    make(map[string]int),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+
+	return f
+}
+
+// set sets the response of an endpoint.
+func (f *fakeFlow) set(endpoint string, rs response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses[endpoint] = rs
+}
+
+// calls returns the number of requests made to an endpoint.
+func (f *fakeFlow) calls(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.counts[endpoint]
+}
+
+// serve answers a request with the response of its endpoint.
+func (f *fakeFlow) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.counts[r.URL.Path]++
+	rs, set := f.responses[r.URL.Path]
+	f.mu.Unlock()
+
+	if !set || rs.status == 0 {
+		rs = failed
+	}
+
+	data, _ := jsoniter.Marshal(rs.body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rs.status)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,162 @@
+package checkout
+
+import (
+	"sync"
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned by a Repository when the record doesn't exist.
+	ErrNotFound = errors.New("order not found")
+
+	// ErrExists is returned by a Repository when creating a record for a commerce order that already has one.
+	ErrExists = errors.New("order already exists")
+)
+
+// Record is an order tracked by a Checkout.
+type Record struct {
+	// CommerceOrder identifies the order, as sent to Flow.
+	CommerceOrder string `json:"commerceOrder"`
+
+	// State is the current state of the order.
+	State State `json:"state"`
+
+	// PreviousState is the state the order was in before its last change.
+	PreviousState State `json:"previousState,omitempty"`
+
+	// EventPending reports that the Event of the last change wasn't delivered to Options.OnEvent yet.
+	EventPending bool `json:"eventPending,omitempty"`
+
+	// Request is the request the order was created with.
+	Request flow.OrderRequest `json:"request"`
+
+	// FlowOrder is the Flow identifier of the order, once created.
+	FlowOrder int `json:"flowOrder,omitempty"`
+
+	// Token is the token of the order, once created.
+	Token string `json:"token,omitempty"`
+
+	// PaymentURL is the URL the payer is redirected to, once created.
+	PaymentURL string `json:"paymentUrl,omitempty"`
+
+	// RefundOrderID is the refundCommerceOrder of the last refund, stored before it's requested so a refund with an
+	// unknown outcome can be found in Flow.
+	RefundOrderID string `json:"refundOrderId,omitempty"`
+
+	// RefundToken is the token of the refund, once requested.
+	RefundToken string `json:"refundToken,omitempty"`
+
+	// Created is the time the order was stored.
+	Created time.Time `json:"created"`
+
+	// Updated is the time of the last change of state.
+	Updated time.Time `json:"updated"`
+
+	// PollAttempts is the number of times the order was checked by the Poller.
+	PollAttempts int `json:"pollAttempts,omitempty"`
+
+	// NextPoll is the time the order is due to be checked again by the Poller.
+	NextPoll time.Time `json:"nextPoll,omitempty"`
+}
+
+// Repository stores the orders of a Checkout. Implementations must be safe for concurrent use.
+type Repository interface {
+	// Create stores a new record. It must return ErrExists if the commerce order already has one.
+	Create(record Record) error
+
+	// Get returns the record of a commerce order, or ErrNotFound.
+	Get(commerceOrder string) (*Record, error)
+
+	// GetByRefundToken returns the record with the refund token, or ErrNotFound.
+	GetByRefundToken(token string) (*Record, error)
+
+	// Update replaces the stored record of the commerce order.
+	Update(record Record) error
+
+	// ListByState returns the records in a state.
+	ListByState(state State) ([]Record, error)
+}
+
+// MemoryRepository is a Repository that keeps the records in memory.
+type MemoryRepository struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryRepository creates an empty *MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		records: make(map[string]Record),
+	}
+}
+
+// Create stores a new record.
+func (r *MemoryRepository) Create(record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[record.CommerceOrder]; ok {
+		return ErrExists
+	}
+
+	r.records[record.CommerceOrder] = record
+	return nil
+}
+
+// Get returns the record of a commerce order.
+func (r *MemoryRepository) Get(commerceOrder string) (*Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[commerceOrder]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &record, nil
+}
+
+// GetByRefundToken returns the record with the refund token.
+func (r *MemoryRepository) GetByRefundToken(token string) (*Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.records {
+		if record.RefundToken == token {
+			return &record, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// Update replaces the stored record of the commerce order.
+func (r *MemoryRepository) Update(record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[record.CommerceOrder]; !ok {
+		return ErrNotFound
+	}
+
+	r.records[record.CommerceOrder] = record
+	return nil
+}
+
+// ListByState returns the records in a state.
+func (r *MemoryRepository) ListByState(state State) ([]Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var records []Record
+	for _, record := range r.records {
+		if record.State == state {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
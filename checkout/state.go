@@ -0,0 +1,77 @@
+package checkout
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+)
+
+// State is the state of an order in its lifecycle.
+type State string
+
+const (
+	// StateCreated is an order stored but not yet known to be created in Flow.
+	StateCreated State = "created"
+
+	// StateFailed is an order that Flow refused to create.
+	StateFailed State = "failed"
+
+	// StateAwaiting is an order created in Flow and waiting to be paid.
+	StateAwaiting State = "awaiting"
+
+	// StatePayed is an order that was paid.
+	StatePayed State = "payed"
+
+	// StateRefunding is a paid order whose refund was requested and not yet completed.
+	StateRefunding State = "refunding"
+
+	// StateRejected is an order whose payment was rejected.
+	StateRejected State = "rejected"
+
+	// StateCanceled is an order canceled before it was paid.
+	StateCanceled State = "canceled"
+
+	// StateExpired is an order that wasn't paid before its timeout.
+	StateExpired State = "expired"
+
+	// StateRefunded is a paid order that was refunded.
+	StateRefunded State = "refunded"
+)
+
+// transitions are the states each state can change to.
+var transitions = map[State][]State{
+	StateCreated:   {StateAwaiting, StateFailed, StatePayed, StateRejected, StateCanceled, StateExpired},
+	StateAwaiting:  {StatePayed, StateRejected, StateCanceled, StateExpired},
+	StatePayed:     {StateRefunding},
+	StateRefunding: {StateRefunded, StatePayed},
+}
+
+// CanTransition reports whether an order in the state can change to the next one.
+func (s State) CanTransition(next State) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Final reports whether no other state can follow the state.
+func (s State) Final() bool {
+	return len(transitions[s]) == 0
+}
+
+// stateForStatus returns the state matching the status of a Flow order.
+func stateForStatus(status int) (State, bool) {
+	switch status {
+	case flow.OrderStatusAwaitingPayment:
+		return StateAwaiting, true
+	case flow.OrderStatusPayed:
+		return StatePayed, true
+	case flow.OrderStatusRejected:
+		return StateRejected, true
+	case flow.OrderStatusCanceled:
+		return StateCanceled, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,63 @@
+package checkout
+
+import (
+	"testing"
+)
+
+// TestStateTransitions checks the changes allowed by the state machine.
+func TestStateTransitions(t *testing.T) {
+	tests := []struct {
+		from State
+		to   State
+		want bool
+	}{
+		{from: StateCreated, to: StateAwaiting, want: true},
+		{from: StateCreated, to: StateFailed, want: true},
+		{from: StateCreated, to: StatePayed, want: true},
+		{from: StateCreated, to: StateExpired, want: true},
+		{from: StateCreated, to: StateRefunding, want: false},
+		{from: StateAwaiting, to: StatePayed, want: true},
+		{from: StateAwaiting, to: StateRejected, want: true},
+		{from: StateAwaiting, to: StateCanceled, want: true},
+		{from: StateAwaiting, to: StateExpired, want: true},
+		{from: StateAwaiting, to: StateFailed, want: false},
+		{from: StateAwaiting, to: StateCreated, want: false},
+		{from: StatePayed, to: StateRefunding, want: true},
+		{from: StatePayed, to: StateRefunded, want: false},
+		{from: StateRefunding, to: StateRefunded, want: true},
+		{from: StateRefunding, to: StatePayed, want: true},
+		{from: StateExpired, to: StatePayed, want: false},
+		{from: StateFailed, to: StateAwaiting, want: false},
+		{from: StateRefunded, to: StateRefunding, want: false},
+	}
+
+	for _, test := range tests {
+		if got := test.from.CanTransition(test.to); got != test.want {
+			t.Errorf("%s.CanTransition(%s) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+// TestStateFinal checks which states end the lifecycle of an order.
+func TestStateFinal(t *testing.T) {
+	tests := []struct {
+		state State
+		want  bool
+	}{
+		{state: StateCreated, want: false},
+		{state: StateAwaiting, want: false},
+		{state: StatePayed, want: false},
+		{state: StateRefunding, want: false},
+		{state: StateFailed, want: true},
+		{state: StateRejected, want: true},
+		{state: StateCanceled, want: true},
+		{state: StateExpired, want: true},
+		{state: StateRefunded, want: true},
+	}
+
+	for _, test := range tests {
+		if got := test.state.Final(); got != test.want {
+			t.Errorf("%s.Final() = %v, want %v", test.state, got, test.want)
+		}
+	}
+}
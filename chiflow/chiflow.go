@@ -0,0 +1,16 @@
+// Package chiflow mounts the Flow confirmation callbacks on a chi router.
+package chiflow
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes returns a function that registers the order and refund confirmation handlers on a chi.Router, to be used
+// with chi.Router.Route. The orders are received on POST /order and the refunds on POST /refund.
+func Routes(c *flow.Client, orders flow.OrderCallbacks, refunds flow.RefundCallbacks) func(chi.Router) {
+	return func(r chi.Router) {
+		r.Post("/order", c.HTTPOrderCallbacks(orders))
+		r.Post("/refund", c.HTTPRefundCallbacks(refunds))
+	}
+}
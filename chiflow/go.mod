@@ -0,0 +1,16 @@
+module github.com/CamiloHernandez/go-flow/chiflow
+
+go 1.23.0
+
+require (
+	github.com/CamiloHernandez/go-flow v0.2.0
+	github.com/go-chi/chi/v5 v5.3.2
+)
+
+require (
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
@@ -1,5 +1,10 @@
 package flow
 
+import (
+	"net/http"
+	"time"
+)
+
 const (
 	ProductionURL = "https://www.flow.cl/api"
 	SandboxURL = "https://sandbox.flow.cl/api"
@@ -13,8 +18,54 @@ type Client struct {
 	// SecretKey is uses to sign the API requests.
 	SecretKey string
 
+	// Credentials is optionally set to provide the keys instead of APIKey and SecretKey, so they can be rotated
+	// without creating a new Client.
+	Credentials CredentialsProvider
+
 	// URL is the base URL to be used in the requests. Can be ProductionURL or SandboxURL.
 	URL string
+
+	// HTTPClient is optionally set to make the requests with a custom *http.Client, for example one with a timeout.
+	HTTPClient *http.Client
+
+	// Retry is optionally set to retry the queries that fail because of a network or server error. Requests that
+	// create or change data are never retried.
+	Retry *RetryPolicy
+
+	// IdempotencyStore is optionally set to deliver each confirmation callback only once, even if Flow sends it more
+	// than once.
+	IdempotencyStore IdempotencyStore
+
+	// ClaimLease is the time a callback claimed on the IdempotencyStore is considered to be in delivery. Deliveries of
+	// the same callback received meanwhile are answered with an error status so Flow retries them, and once it expires
+	// the callback can be claimed again. It must be longer than the slowest delivery, including the retries of a
+	// Queue. It defaults to DefaultClaimLease.
+	ClaimLease time.Duration
+
+	// OrderNotFoundCode is optionally set to the error code Flow answers with when an order looked up by its commerce
+	// order doesn't exist. Only when the lookup fails with it, CreateOrderIdempotent releases the reservation of an
+	// order whose creation had an unknown outcome. If zero, the reservation is always kept and ErrOrderOutcomeUnknown
+	// returned, so the order must be resolved by hand.
+	OrderNotFoundCode int
+
+	// Logger is optionally set to log every request made to the Flow API.
+	Logger Logger
+
+	// LogBodies enables logging the bodies of the requests and responses at the debug level. Credentials, signatures
+	// and emails are redacted from them.
+	LogBodies bool
+
+	// Metrics is optionally set to measure the requests made to the Flow API and the callbacks received from it.
+	Metrics Metrics
+
+	// Tracer is optionally set to trace the requests made to the Flow API and the callbacks received from it.
+	Tracer Tracer
+
+	// RateLimits is optionally set to limit the rate and concurrency of the requests made to the Flow API.
+	RateLimits *RateLimits
+
+	// Breaker is optionally set to fail the requests fast with ErrCircuitOpen while the Flow API is failing.
+	Breaker *CircuitBreaker
 }
 
 // NewClient creates a *Client with the given keys. By default it's set to sandbox mode.
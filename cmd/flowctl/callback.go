@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+// callbackSend runs "flowctl callback send". It posts a token to a confirmation URL the same way Flow does, and prints
+// the response.
+func callbackSend(args []string) error {
+	fs := flag.NewFlagSet("flowctl callback send", flag.ContinueOnError)
+	target := fs.String("url", "", "confirmation `URL` to notify, like http://localhost:8080/confirm")
+	token := fs.String("token", "", "token of the order or refund")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum time to wait for the response")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *target == "" || *token == "" {
+		return errors.New("--url and --token are required")
+	}
+
+	httpClient := http.Client{Timeout: *timeout}
+	resp, err := httpClient.PostForm(*target, url.Values{"token": {*token}})
+	if err != nil {
+		return errors.Wrap(err, "unable to send callback")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read response")
+	}
+
+	fmt.Println(resp.Status)
+	if len(body) > 0 {
+		fmt.Println(strings.TrimSpace(string(body)))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("the callback was answered with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// callbackServe runs "flowctl callback serve". It serves HTTPOrderConfirmationCallback and prints the orders it
+// verifies, and logs every callback received to the standard error.
+func callbackServe(args []string) error {
+	var opts options
+	fs := newFlagSet("callback serve", &opts)
+	addr := fs.String("addr", "localhost:8080", "`address` to listen on")
+	path := fs.String("path", "/confirm", "`path` of the confirmation URL")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	handler := c.HTTPOrderConfirmationCallback(func(order *flow.Order) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		err := printOrder(opts, order)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "flowctl:", err)
+		}
+		if opts.output == "table" {
+			fmt.Println()
+		}
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, logCallbacks(handler))
+
+	fmt.Fprintf(os.Stderr, "Listening for confirmations on http://%s%s\n", *addr, *path)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// statusRecorder records the status written to a http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status and writes it.
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// logCallbacks logs the token and response status of each request served by next.
+func logCallbacks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r)
+
+		fmt.Fprintf(os.Stderr, "%s %s token=%q: %d %s\n", r.Method, r.URL.Path, r.FormValue("token"), sr.status,
+			http.StatusText(sr.status))
+	})
+}
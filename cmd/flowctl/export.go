@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/CamiloHernandez/go-flow/export"
+	"github.com/pkg/errors"
+)
+
+// exportOptions are the options of the export commands.
+type exportOptions struct {
+	options
+	format string
+	out    string
+}
+
+// newExportFlagSet creates the flag set of an export command.
+func newExportFlagSet(name string, opts *exportOptions) *flag.FlagSet {
+	fs := newFlagSet(name, &opts.options)
+	fs.StringVar(&opts.format, "format", "csv", "export `format`: csv or ndjson")
+	fs.StringVar(&opts.out, "out", "", "`file` to write to, instead of the standard output")
+	return fs
+}
+
+// writer opens the output of the export, and creates its writer with newWriter.
+func (opts exportOptions) writer(newWriter func(io.Writer, export.Format) (*export.Writer, error)) (*export.Writer,
+	func() error, error) {
+	var out io.WriteCloser = os.Stdout
+	if opts.out != "" {
+		file, err := os.Create(opts.out)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to create output file")
+		}
+		out = file
+	}
+
+	w, err := newWriter(out, export.Format(opts.format))
+	if err != nil {
+		if out != os.Stdout {
+			_ = out.Close()
+		}
+		return nil, nil, err
+	}
+
+	closeFn := func() error {
+		err := w.Flush()
+		if out != os.Stdout {
+			closeErr := out.Close()
+			if err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+
+	return w, closeFn, nil
+}
+
+// exportOrders runs "flowctl export orders". It exports the orders paid between two dates.
+func exportOrders(args []string) (err error) {
+	var opts exportOptions
+	fs := newExportFlagSet("export orders", &opts)
+	from := fs.String("from", "", "first `date` to export, like 2021-01-31")
+	to := fs.String("to", "", "last `date` to export, defaults to --from")
+
+	err = parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := parsePeriod(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	w, closeFn, err := opts.writer(export.NewOrderWriter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := closeFn()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	const pageSize = 100
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		for offset := 0; ; offset += pageSize {
+			var page *flow.PaymentList
+			page, err = c.GetPayments(date, offset, pageSize)
+			if err != nil {
+				return err
+			}
+
+			for _, order := range page.Data {
+				err = w.Write(order)
+				if err != nil {
+					return err
+				}
+			}
+
+			if page.HasMore == 0 || len(page.Data) == 0 {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportRefunds runs "flowctl export refunds". It exports the refunds whose tokens are given as arguments, or read
+// one per line from the standard input if there are none.
+func exportRefunds(args []string) (err error) {
+	var opts exportOptions
+	fs := newExportFlagSet("export refunds", &opts)
+
+	err = fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	tokens := fs.Args()
+	if len(tokens) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			token := strings.TrimSpace(scanner.Text())
+			if token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		if scanner.Err() != nil {
+			return errors.Wrap(scanner.Err(), "unable to read tokens")
+		}
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	w, closeFn, err := opts.writer(export.NewRefundWriter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := closeFn()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, token := range tokens {
+		var status *flow.RefundStatus
+		status, err = c.GetRefundStatus(token)
+		if err != nil {
+			return errors.Wrapf(err, "unable to fetch refund %s", token)
+		}
+
+		err = w.Write(status)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportSettlements runs "flowctl export settlements". It exports the settlements made between two dates.
+func exportSettlements(args []string) (err error) {
+	var opts exportOptions
+	fs := newExportFlagSet("export settlements", &opts)
+	from := fs.String("from", "", "first `date` to export, like 2021-01-31")
+	to := fs.String("to", "", "last `date` to export, defaults to --from")
+
+	err = parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := parsePeriod(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	settlements, err := c.SearchSettlements(start, end)
+	if err != nil {
+		return err
+	}
+
+	w, closeFn, err := opts.writer(export.NewSettlementWriter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := closeFn()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, settlement := range settlements {
+		err = w.Write(settlement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parsePeriod parses the --from and --to dates of a command. The end defaults to the start.
+func parsePeriod(from, to string) (start, end time.Time, err error) {
+	if from == "" {
+		return start, end, errors.New("--from is required")
+	}
+
+	start, err = time.Parse("2006-01-02", from)
+	if err != nil {
+		return start, end, errors.Wrap(err, "invalid --from date")
+	}
+
+	if to == "" {
+		return start, start, nil
+	}
+
+	end, err = time.Parse("2006-01-02", to)
+	if err != nil {
+		return start, end, errors.Wrap(err, "invalid --to date")
+	}
+
+	if end.Before(start) {
+		return start, end, errors.New("--to is before --from")
+	}
+
+	return start, end, nil
+}
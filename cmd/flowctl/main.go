@@ -0,0 +1,104 @@
+// Command flowctl operates a Flow account from the command line, to look up orders and manage refunds without writing
+// Go.
+//
+// Usage:
+//
+//	flowctl order get (--token TOKEN | --commerce-id ID | --flow-id ID) [options]
+//	flowctl order create --commerce-order ID --subject SUBJECT --amount AMOUNT --email EMAIL [options]
+//	flowctl refund create --commerce-order ID --email EMAIL --amount AMOUNT --callback-url URL [options]
+//	flowctl refund status --token TOKEN [options]
+//	flowctl refund cancel --token TOKEN [options]
+//	flowctl callback send --url URL --token TOKEN
+//	flowctl callback serve [--addr ADDRESS] [--path PATH] [options]
+//	flowctl export orders --from DATE [--to DATE] [--format csv|ndjson] [--out FILE] [options]
+//	flowctl export refunds [--format csv|ndjson] [--out FILE] [options] [TOKEN...]
+//	flowctl export settlements --from DATE [--to DATE] [--format csv|ndjson] [--out FILE] [options]
+//
+// The credentials are read from the file given with --config, or else from the environment variables read by
+// flow.NewClientFromEnv. The environment can be overridden with --sandbox or --production, and the results are
+// printed as a table, or as JSON with --output json.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of flowctl.
+type command struct {
+	// summary is a one line description of the command.
+	summary string
+
+	// run runs the command with its arguments.
+	run func(args []string) error
+}
+
+// commands are the commands of flowctl, by group and name.
+var commands = map[string]map[string]command{
+	"callback": {
+		"send":  {"Post a token to a confirmation URL like Flow does", callbackSend},
+		"serve": {"Serve a confirmation URL and print the orders it verifies", callbackServe},
+	},
+	"export": {
+		"orders":      {"Export the orders paid between two dates", exportOrders},
+		"refunds":     {"Export refunds by token", exportRefunds},
+		"settlements": {"Export the settlements made between two dates", exportSettlements},
+	},
+	"order": {
+		"get":    {"Fetch an order by token, commerce ID or Flow ID", orderGet},
+		"create": {"Create a payment order", orderCreate},
+	},
+	"refund": {
+		"create": {"Request a refund", refundCreate},
+		"status": {"Fetch the status of a refund", refundStatus},
+		"cancel": {"Cancel a refund", refundCancel},
+	},
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]][os.Args[2]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[3:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "flowctl:", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the list of commands.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: flowctl <command> <subcommand> [options]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	var names []string
+	for group, cmds := range commands {
+		for name := range cmds {
+			names = append(names, group+" "+name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parts := strings.SplitN(name, " ", 2)
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[parts[0]][parts[1]].summary)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'flowctl <command> <subcommand> -h' for the options of a command.")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// options are the options shared by all the commands.
+type options struct {
+	config     string
+	sandbox    bool
+	production bool
+	output     string
+}
+
+// newFlagSet creates the flag set of a command, with the shared options registered in opts.
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("flowctl "+name, flag.ContinueOnError)
+	fs.StringVar(&opts.config, "config", "", "JSON or YAML `file` with the client configuration")
+	fs.BoolVar(&opts.sandbox, "sandbox", false, "use the sandbox environment")
+	fs.BoolVar(&opts.production, "production", false, "use the production environment")
+	fs.StringVar(&opts.output, "output", "table", "output `format`: table or json")
+	return fs
+}
+
+// client creates the client configured by the options.
+func (opts options) client() (*flow.Client, error) {
+	if opts.sandbox && opts.production {
+		return nil, errors.New("--sandbox and --production can't be used together")
+	}
+
+	if opts.output != "table" && opts.output != "json" {
+		return nil, errors.Errorf("unknown output format %q", opts.output)
+	}
+
+	var cfg *flow.Config
+	var err error
+	if opts.config != "" {
+		cfg, err = flow.LoadConfig(opts.config)
+	} else {
+		cfg, err = flow.ConfigFromEnv()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The environment switches replace the configured environment, along with its URL override, and the result is
+	// validated again when creating the client.
+	if cfg.Environment == "" {
+		cfg.Environment = flow.EnvironmentSandbox
+	}
+
+	switch {
+	case opts.sandbox && cfg.Environment != flow.EnvironmentSandbox:
+		cfg.Environment = flow.EnvironmentSandbox
+		cfg.URL = ""
+	case opts.production && cfg.Environment != flow.EnvironmentProduction:
+		cfg.Environment = flow.EnvironmentProduction
+		cfg.URL = ""
+	}
+
+	return cfg.NewClient()
+}
+
+// field is a row of a table.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// print writes value as indented JSON, or its fields as a table, depending on the output option.
+func (opts options) print(value interface{}, fields []field) error {
+	if opts.output == "json" {
+		data, err := jsoniter.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "unable to encode output")
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s\t%v\n", f.name, f.value)
+	}
+
+	return tw.Flush()
+}
+
+// parse parses the arguments of a command, failing if there are positional arguments left.
+func parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	return nil
+}
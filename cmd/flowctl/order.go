@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+// orderGet runs "flowctl order get".
+func orderGet(args []string) error {
+	var opts options
+	fs := newFlagSet("order get", &opts)
+	token := fs.String("token", "", "token of the order")
+	commerceID := fs.String("commerce-id", "", "commerce order of the order")
+	flowID := fs.Int("flow-id", 0, "Flow ID of the order")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	set := 0
+	for _, given := range []bool{*token != "", *commerceID != "", *flowID != 0} {
+		if given {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of --token, --commerce-id or --flow-id must be given")
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	var order *flow.Order
+	switch {
+	case *token != "":
+		order, err = c.GetOrder(*token)
+	case *commerceID != "":
+		order, err = c.GetOrderByCommerceID(*commerceID)
+	default:
+		order, err = c.GetOrderByFlowID(*flowID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printOrder(opts, order)
+}
+
+// orderCreate runs "flowctl order create".
+func orderCreate(args []string) error {
+	var opts options
+	var or flow.OrderRequest
+	fs := newFlagSet("order create", &opts)
+	fs.StringVar(&or.CommerceOrder, "commerce-order", "", "commerce order of the new order")
+	fs.StringVar(&or.Subject, "subject", "", "reason for the payment")
+	fs.Uint64Var(&or.Amount, "amount", 0, "amount to charge")
+	fs.StringVar(&or.PayerEmail, "email", "", "email of the payer")
+	fs.StringVar(&or.Currency, "currency", "", "currency of the order")
+	fs.IntVar(&or.PaymentMethod, "payment-method", 0, "allowed payment methods, all by default")
+	fs.StringVar(&or.ConfirmationURL, "confirmation-url", "", "URL notified when the order is paid")
+	fs.StringVar(&or.ReturnURL, "return-url", "", "URL the payer returns to")
+	fs.Uint64Var(&or.TimeoutSeconds, "timeout", 0, "seconds the order stays payable")
+	byEmail := fs.Bool("by-email", false, "send the payment link to the payer by email")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	var result flow.OrderResponse
+	if *byEmail {
+		result.FlowID, result.Token, err = c.CreateEmailOrder(or)
+	} else {
+		var created *flow.OrderResponse
+		created, err = c.CreateOrder(or)
+		if created != nil {
+			result = *created
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := []field{
+		{"Flow order", result.FlowID},
+		{"Token", result.Token},
+	}
+	if result.URL != "" {
+		fields = append(fields, field{"Payment URL", result.GetPaymentURL()})
+	}
+
+	return opts.print(result, fields)
+}
+
+// printOrder prints an order.
+func printOrder(opts options, order *flow.Order) error {
+	return opts.print(order, []field{
+		{"Flow order", order.FlowOrder},
+		{"Commerce order", order.CommerceOrder},
+		{"Status", orderStatusName(order.Status)},
+		{"Subject", order.Subject},
+		{"Amount", order.Amount + " " + order.Currency},
+		{"Payer", order.PayerEmail},
+		{"Requested", order.RequestDate},
+		{"Paid", order.PaymentData.Date},
+		{"Media", order.PaymentData.Media},
+		{"Fee", order.PaymentData.Fee},
+		{"Balance", order.PaymentData.Balance},
+		{"Transfer date", order.PaymentData.TransferDate},
+	})
+}
+
+// orderStatusName is the name of an order status.
+func orderStatusName(status int) string {
+	switch status {
+	case flow.OrderStatusAwaitingPayment:
+		return "awaiting payment"
+	case flow.OrderStatusPayed:
+		return "payed"
+	case flow.OrderStatusRejected:
+		return "rejected"
+	case flow.OrderStatusCanceled:
+		return "canceled"
+	}
+
+	return "unknown"
+}
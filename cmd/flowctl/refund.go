@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+// refundCreate runs "flowctl refund create".
+func refundCreate(args []string) error {
+	var opts options
+	var r flow.Refund
+	fs := newFlagSet("refund create", &opts)
+	fs.StringVar(&r.OrderID, "commerce-order", "", "commerce order of the refund")
+	fs.StringVar(&r.ReceiverEmail, "email", "", "email of the refunded payer")
+	fs.Uint64Var(&r.Amount, "amount", 0, "amount to refund")
+	fs.StringVar(&r.CallbackURL, "callback-url", "", "URL notified when the refund status changes")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if r.OrderID == "" || r.ReceiverEmail == "" || r.Amount == 0 || r.CallbackURL == "" {
+		return errors.New("--commerce-order, --email, --amount and --callback-url are required")
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	status, err := c.CreateRefund(r)
+	if err != nil {
+		return err
+	}
+
+	return printRefund(opts, status)
+}
+
+// refundStatus runs "flowctl refund status".
+func refundStatus(args []string) error {
+	return refundByToken("refund status", args, flow.Client.GetRefundStatus)
+}
+
+// refundCancel runs "flowctl refund cancel".
+func refundCancel(args []string) error {
+	return refundByToken("refund cancel", args, flow.Client.CancelRefund)
+}
+
+// refundByToken runs a command that calls fn with the token given by --token.
+func refundByToken(name string, args []string, fn func(flow.Client, string) (*flow.RefundStatus, error)) error {
+	var opts options
+	fs := newFlagSet(name, &opts)
+	token := fs.String("token", "", "token of the refund")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *token == "" {
+		return errors.New("--token is required")
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	status, err := fn(*c, *token)
+	if err != nil {
+		return err
+	}
+
+	return printRefund(opts, status)
+}
+
+// printRefund prints the status of a refund.
+func printRefund(opts options, status *flow.RefundStatus) error {
+	return opts.print(status, []field{
+		{"Token", status.Token},
+		{"Refund order", status.RefundOrder},
+		{"Date", status.Date},
+		{"Status", status.Status},
+		{"Amount", status.Amount},
+		{"Fee", status.Fee},
+	})
+}
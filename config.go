@@ -0,0 +1,241 @@
+package flow
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvironmentSandbox is the environment used for testing, on SandboxURL.
+	EnvironmentSandbox = "sandbox"
+
+	// EnvironmentProduction is the environment with real payments, on ProductionURL.
+	EnvironmentProduction = "production"
+)
+
+// Duration is a time.Duration read from configuration files as a string like "10s" or "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration from a JSON string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := jsoniter.Unmarshal(data, &value)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+
+	return d.parse(value)
+}
+
+// UnmarshalYAML parses a duration from a YAML string.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+
+	return d.parse(value)
+}
+
+// parse parses a duration string. An empty string is a zero duration.
+func (d *Duration) parse(value string) error {
+	if value == "" {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// RetryPolicy configures how a Client retries the queries that fail because of a network or server error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a query is made before giving up. It defaults to 3.
+	MaxAttempts int `json:"maxAttempts" yaml:"maxAttempts"`
+
+	// Backoff is the time waited before the first retry, doubled on each of the following ones. It defaults to half
+	// a second.
+	Backoff Duration `json:"backoff" yaml:"backoff"`
+}
+
+// attempts returns the maximum number of attempts of a query.
+func (rp *RetryPolicy) attempts() int {
+	if rp.MaxAttempts <= 0 {
+		return 3
+	}
+
+	return rp.MaxAttempts
+}
+
+// backoff returns the time to wait after the given failed attempt.
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := time.Duration(rp.Backoff)
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	return backoff << uint(attempt-1)
+}
+
+// Config is the configuration of a Client, as read by LoadConfig and ConfigFromEnv.
+type Config struct {
+	// APIKey is the access key provided by Flow.
+	APIKey string `json:"apiKey" yaml:"apiKey"`
+
+	// SecretKey is used to sign the API requests.
+	SecretKey string `json:"secretKey" yaml:"secretKey"`
+
+	// Environment is either EnvironmentSandbox or EnvironmentProduction. It defaults to EnvironmentSandbox.
+	Environment string `json:"environment" yaml:"environment"`
+
+	// URL is optionally set to override the base URL of the environment.
+	URL string `json:"url" yaml:"url"`
+
+	// Timeout is the maximum time a request can take. No timeout is applied if zero.
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+
+	// Retry is optionally set to retry the failed queries.
+	Retry *RetryPolicy `json:"retry" yaml:"retry"`
+}
+
+// LoadConfig reads a Config from a JSON or YAML file, depending on its extension, and validates it. Unknown keys are
+// rejected in both formats, so misspelled settings aren't silently ignored.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read config file")
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := jsoniter.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &cfg)
+	default:
+		return nil, errors.Errorf("unsupported config file extension %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse config file")
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Validate checks that the keys are set, and that the environment is valid and isn't mixed with the URL of the other
+// environment.
+func (cfg Config) Validate() error {
+	if cfg.APIKey == "" || cfg.SecretKey == "" {
+		return errors.New("invalid config: the API key and the secret key must be set")
+	}
+
+	url := strings.TrimRight(cfg.URL, "/")
+	switch cfg.Environment {
+	case "", EnvironmentSandbox:
+		if url == ProductionURL {
+			return errors.New("invalid config: the sandbox environment can't use the production URL")
+		}
+	case EnvironmentProduction:
+		if url == SandboxURL {
+			return errors.New("invalid config: the production environment can't use the sandbox URL")
+		}
+	default:
+		return errors.Errorf("invalid config: unknown environment %q", cfg.Environment)
+	}
+
+	return nil
+}
+
+// NewClient validates the configuration and creates a *Client with it.
+func (cfg Config) NewClient() (*Client, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewClient(cfg.APIKey, cfg.SecretKey)
+	if cfg.Environment == EnvironmentProduction {
+		c.SetProduction()
+	}
+	if cfg.URL != "" {
+		c.URL = strings.TrimRight(cfg.URL, "/")
+	}
+	if cfg.Timeout > 0 {
+		c.HTTPClient = &http.Client{Timeout: time.Duration(cfg.Timeout)}
+	}
+	c.Retry = cfg.Retry
+
+	return c, nil
+}
+
+// NewClientFromEnv creates a *Client configured by the environment variables read by ConfigFromEnv.
+func NewClientFromEnv() (*Client, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.NewClient()
+}
+
+// ConfigFromEnv reads a Config from the following environment variables:
+//
+//	FLOW_API_KEY             - The API key. Required.
+//	FLOW_SECRET_KEY          - The secret key. Required.
+//	FLOW_ENVIRONMENT         - "sandbox" or "production". Defaults to "sandbox".
+//	FLOW_URL                 - Overrides the base URL of the environment.
+//	FLOW_TIMEOUT             - The request timeout, like "10s".
+//	FLOW_RETRY_MAX_ATTEMPTS  - Enables retrying the failed queries up to this number of attempts.
+//	FLOW_RETRY_BACKOFF       - The time waited before the first retry, like "500ms".
+func ConfigFromEnv() (*Config, error) {
+	cfg := Config{
+		APIKey:      os.Getenv("FLOW_API_KEY"),
+		SecretKey:   os.Getenv("FLOW_SECRET_KEY"),
+		Environment: os.Getenv("FLOW_ENVIRONMENT"),
+		URL:         os.Getenv("FLOW_URL"),
+	}
+
+	err := cfg.Timeout.parse(os.Getenv("FLOW_TIMEOUT"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid FLOW_TIMEOUT")
+	}
+
+	if attempts := os.Getenv("FLOW_RETRY_MAX_ATTEMPTS"); attempts != "" {
+		cfg.Retry = &RetryPolicy{}
+
+		cfg.Retry.MaxAttempts, err = strconv.Atoi(attempts)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid FLOW_RETRY_MAX_ATTEMPTS")
+		}
+
+		err = cfg.Retry.Backoff.parse(os.Getenv("FLOW_RETRY_BACKOFF"))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid FLOW_RETRY_BACKOFF")
+		}
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,251 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// OrderHandlerFunc processes an order received by a confirmation handler. If it returns an error, the handler answers
+// with an error status so Flow retries the delivery.
+type OrderHandlerFunc func(ctx context.Context, order *Order) error
+
+// RefundHandlerFunc processes a refund received by a confirmation handler. If it returns an error, the handler answers
+// with an error status so Flow retries the delivery.
+type RefundHandlerFunc func(ctx context.Context, refund *RefundStatus) error
+
+// OrderCallbacks are the functions called by an order confirmation handler depending on the order's status. Any of
+// them might be nil.
+type OrderCallbacks struct {
+	// OnPaid is called for orders with the OrderStatusPayed status.
+	OnPaid OrderHandlerFunc
+
+	// OnRejected is called for orders with the OrderStatusRejected status.
+	OnRejected OrderHandlerFunc
+
+	// OnCanceled is called for orders with the OrderStatusCanceled status.
+	OnCanceled OrderHandlerFunc
+
+	// OnPending is called for orders with the OrderStatusAwaitingPayment status.
+	OnPending OrderHandlerFunc
+}
+
+// forStatus returns the function to call for an order status.
+func (oc OrderCallbacks) forStatus(status int) OrderHandlerFunc {
+	switch status {
+	case OrderStatusPayed:
+		return oc.OnPaid
+	case OrderStatusRejected:
+		return oc.OnRejected
+	case OrderStatusCanceled:
+		return oc.OnCanceled
+	case OrderStatusAwaitingPayment:
+		return oc.OnPending
+	}
+
+	return nil
+}
+
+// RefundCallbacks are the functions called by a refund confirmation handler depending on the refund's status. Any of
+// them might be nil.
+type RefundCallbacks struct {
+	// OnCreated is called for refunds with the RefundStatusCreated status.
+	OnCreated RefundHandlerFunc
+
+	// OnAccepted is called for refunds with the RefundStatusAccepted status.
+	OnAccepted RefundHandlerFunc
+
+	// OnRefunded is called for refunds with the RefundStatusRefunded status.
+	OnRefunded RefundHandlerFunc
+
+	// OnRejected is called for refunds with the RefundStatusRejected status.
+	OnRejected RefundHandlerFunc
+
+	// OnCanceled is called for refunds with the RefundStatusCanceled status.
+	OnCanceled RefundHandlerFunc
+}
+
+// forStatus returns the function to call for a refund status.
+func (rc RefundCallbacks) forStatus(status string) RefundHandlerFunc {
+	switch status {
+	case RefundStatusCreated:
+		return rc.OnCreated
+	case RefundStatusAccepted:
+		return rc.OnAccepted
+	case RefundStatusRefunded:
+		return rc.OnRefunded
+	case RefundStatusRejected:
+		return rc.OnRejected
+	case RefundStatusCanceled:
+		return rc.OnCanceled
+	}
+
+	return nil
+}
+
+// ConfirmOrder processes the token of an order confirmation sent by Flow, independently of the HTTP framework used to
+// receive it. It validates the token and calls the function of callbacks matching the order's status. It returns the
+// HTTP status that should be answered to Flow:
+//
+//	200 OK                    - The order was delivered, or its status has no function in callbacks.
+//	400 Bad Request           - The token is empty.
+//	409 Conflict              - The same notification is being delivered by another request, Flow should retry.
+//	500 Internal Server Error - The order couldn't be fetched or the callback failed, Flow should retry.
+//
+// 200 OK is only answered once the notification is known to be delivered, so Flow keeps retrying it until then.
+func (c *Client) ConfirmOrder(ctx context.Context, token string, callbacks OrderCallbacks) int {
+	return c.confirmOrder(ctx, token, callbacks, http.StatusOK, nil)
+}
+
+// ConfirmRefund processes the token of a refund confirmation sent by Flow, independently of the HTTP framework used to
+// receive it. It validates the token and calls the function of callbacks matching the refund's status. It returns the
+// HTTP status that should be answered to Flow, following the same rules as ConfirmOrder.
+func (c *Client) ConfirmRefund(ctx context.Context, token string, callbacks RefundCallbacks) int {
+	return c.confirmRefund(ctx, token, callbacks, http.StatusOK, nil)
+}
+
+// confirmOrder processes an order confirmation token and returns the status to answer with. Orders with a status
+// without function in callbacks are answered with the unhandled status. If q is set, the callback is processed by the
+// queue instead of before answering.
+func (c *Client) confirmOrder(ctx context.Context, token string, callbacks OrderCallbacks, unhandled int,
+	q *Queue) int {
+	ctx, span := c.startSpan(ctx, "flow order callback")
+	defer span.End()
+
+	if token == "" {
+		c.observeCallback("order", outcomeInvalidToken)
+		span.SetError(ErrInvalidToken)
+		return http.StatusBadRequest
+	}
+
+	order, err := c.GetOrderContext(ctx, token)
+	if err != nil {
+		c.observeCallback("order", resolveOutcome(err))
+		span.SetError(err)
+		return http.StatusInternalServerError
+	}
+
+	var status int
+	fn := callbacks.forStatus(order.Status)
+	switch {
+	case fn == nil:
+		status = unhandled
+	case q != nil:
+		status = c.enqueue(ctx, q, orderKey(order), func(ctx context.Context) error { return fn(ctx, order) })
+	default:
+		status = c.dispatch(orderKey(order), func() error { return fn(ctx, order) })
+	}
+
+	c.observeCallback("order", callbackOutcome(status, orderStatusName(order.Status)))
+	span.SetAttribute("flow.flowOrder", order.FlowOrder)
+	span.SetAttribute("flow.commerceOrder", order.CommerceOrder)
+	span.SetAttribute("flow.status", orderStatusName(order.Status))
+	span.SetAttribute("http.status_code", status)
+	return status
+}
+
+// confirmRefund processes a refund confirmation token and returns the status to answer with. Refunds with a status
+// without function in callbacks are answered with the unhandled status. If q is set, the callback is processed by the
+// queue instead of before answering.
+func (c *Client) confirmRefund(ctx context.Context, token string, callbacks RefundCallbacks, unhandled int,
+	q *Queue) int {
+	ctx, span := c.startSpan(ctx, "flow refund callback")
+	defer span.End()
+
+	if token == "" {
+		c.observeCallback("refund", outcomeInvalidToken)
+		span.SetError(ErrInvalidToken)
+		return http.StatusBadRequest
+	}
+
+	refund, err := c.GetRefundStatusContext(ctx, token)
+	if err != nil {
+		c.observeCallback("refund", resolveOutcome(err))
+		span.SetError(err)
+		return http.StatusInternalServerError
+	}
+
+	var status int
+	fn := callbacks.forStatus(refund.Status)
+	switch {
+	case fn == nil:
+		status = unhandled
+	case q != nil:
+		status = c.enqueue(ctx, q, refundKey(refund), func(ctx context.Context) error { return fn(ctx, refund) })
+	default:
+		status = c.dispatch(refundKey(refund), func() error { return fn(ctx, refund) })
+	}
+
+	c.observeCallback("refund", callbackOutcome(status, refund.Status))
+	span.SetAttribute("flow.flowRefundOrder", refund.RefundOrder)
+	span.SetAttribute("flow.status", refund.Status)
+	span.SetAttribute("http.status_code", status)
+	return status
+}
+
+// dispatch delivers a notification identified by key, and returns the status to answer with. Notifications being
+// delivered by another request are answered with 409 Conflict, so Flow retries them until they're delivered.
+func (c *Client) dispatch(key string, fn func() error) int {
+	state, err := c.claim(key)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+
+	switch state {
+	case ClaimInFlight:
+		return http.StatusConflict
+	case ClaimNew:
+		err = c.deliver(key, fn)
+		if err != nil {
+			return http.StatusInternalServerError
+		}
+	}
+
+	return http.StatusOK
+}
+
+// callbackToken reads the token sent by Flow on a callback request. It returns an empty string if the request doesn't
+// have exactly one token.
+func callbackToken(r *http.Request) string {
+	err := r.ParseForm()
+	if err != nil {
+		return ""
+	}
+
+	return CallbackToken(r.Form)
+}
+
+// CallbackToken returns the token of the form values of a callback request, including the ones of its query, for the
+// handlers of other HTTP frameworks to pass to ConfirmOrder or ConfirmRefund. It returns an empty string if the values
+// don't have exactly one token, which ConfirmOrder and ConfirmRefund reject.
+func CallbackToken(values url.Values) string {
+	tokens := values["token"]
+	if len(tokens) != 1 {
+		return ""
+	}
+
+	return tokens[0]
+}
+
+// acceptedOrder adapts a function that only receives payed orders to OrderCallbacks.
+func acceptedOrder(onAccepted func(*Order)) OrderCallbacks {
+	return OrderCallbacks{
+		OnPaid: func(_ context.Context, order *Order) error {
+			onAccepted(order)
+			return nil
+		},
+	}
+}
+
+// acceptedRefund adapts a function that only receives accepted or refunded refunds to RefundCallbacks.
+func acceptedRefund(onAccepted func(*RefundStatus)) RefundCallbacks {
+	fn := func(_ context.Context, refund *RefundStatus) error {
+		onAccepted(refund)
+		return nil
+	}
+
+	return RefundCallbacks{
+		OnAccepted: fn,
+		OnRefunded: fn,
+	}
+}
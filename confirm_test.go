@@ -0,0 +1,97 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// TestConfirmOrder checks that each order status is delivered to its own callback.
+func TestConfirmOrder(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	var called string
+	record := func(name string) OrderHandlerFunc {
+		return func(context.Context, *Order) error {
+			called = name
+			return nil
+		}
+	}
+
+	callbacks := OrderCallbacks{
+		OnPaid:     record("OnPaid"),
+		OnRejected: record("OnRejected"),
+		OnCanceled: record("OnCanceled"),
+	}
+
+	tests := []struct {
+		status     int
+		want       int
+		wantCalled string
+	}{
+		{status: OrderStatusPayed, want: http.StatusOK, wantCalled: "OnPaid"},
+		{status: OrderStatusRejected, want: http.StatusOK, wantCalled: "OnRejected"},
+		{status: OrderStatusCanceled, want: http.StatusOK, wantCalled: "OnCanceled"},
+		{status: OrderStatusAwaitingPayment, want: http.StatusOK},
+	}
+
+	c := flow.client()
+	for i, test := range tests {
+		flow.setOrder("token", &Order{FlowOrder: i, Status: test.status})
+		called = ""
+
+		if got := c.ConfirmOrder(context.Background(), "token", callbacks); got != test.want {
+			t.Errorf("status %d: ConfirmOrder() = %d, want %d", test.status, got, test.want)
+		}
+		if called != test.wantCalled {
+			t.Errorf("status %d: called %q, want %q", test.status, called, test.wantCalled)
+		}
+	}
+}
+
+// TestConfirmRefund checks that each refund status is delivered to its own callback.
+func TestConfirmRefund(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	var called string
+	record := func(name string) RefundHandlerFunc {
+		return func(context.Context, *RefundStatus) error {
+			called = name
+			return nil
+		}
+	}
+
+	callbacks := RefundCallbacks{
+		OnCreated:  record("OnCreated"),
+		OnAccepted: record("OnAccepted"),
+		OnRefunded: record("OnRefunded"),
+		OnRejected: record("OnRejected"),
+	}
+
+	tests := []struct {
+		status     string
+		want       int
+		wantCalled string
+	}{
+		{status: RefundStatusCreated, want: http.StatusOK, wantCalled: "OnCreated"},
+		{status: RefundStatusAccepted, want: http.StatusOK, wantCalled: "OnAccepted"},
+		{status: RefundStatusRefunded, want: http.StatusOK, wantCalled: "OnRefunded"},
+		{status: RefundStatusRejected, want: http.StatusOK, wantCalled: "OnRejected"},
+		{status: RefundStatusCanceled, want: http.StatusOK},
+	}
+
+	c := flow.client()
+	for _, test := range tests {
+		flow.setRefund("token", &RefundStatus{RefundOrder: "1", Status: test.status})
+		called = ""
+
+		if got := c.ConfirmRefund(context.Background(), "token", callbacks); got != test.want {
+			t.Errorf("status %s: ConfirmRefund() = %d, want %d", test.status, got, test.want)
+		}
+		if called != test.wantCalled {
+			t.Errorf("status %s: called %q, want %q", test.status, called, test.wantCalled)
+		}
+	}
+}
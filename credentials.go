@@ -0,0 +1,206 @@
+package flow
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// Credentials are the keys used to authenticate with Flow.
+type Credentials struct {
+	// APIKey is the access key provided by Flow.
+	APIKey string `json:"apiKey"`
+
+	// SecretKey is used to sign the API requests.
+	SecretKey string `json:"secretKey"`
+
+	// PreviousSecretKeys are former secret keys that are still accepted when verifying signatures, so signed links and
+	// payloads keep working while a secret key is being rotated. They are never used to sign.
+	PreviousSecretKeys []string `json:"previousSecretKeys,omitempty"`
+}
+
+// CredentialsProvider provides the credentials of a Client every time they are needed, so they can change without
+// creating a new Client. Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// StaticCredentials is a CredentialsProvider that always provides the same credentials.
+type StaticCredentials Credentials
+
+// Credentials returns the credentials.
+func (sc StaticCredentials) Credentials() (Credentials, error) {
+	return Credentials(sc), nil
+}
+
+// EnvCredentials is a CredentialsProvider that reads the credentials from environment variables each time they are
+// needed.
+type EnvCredentials struct {
+	// APIKeyVar is the variable holding the API key. It defaults to FLOW_API_KEY.
+	APIKeyVar string
+
+	// SecretKeyVar is the variable holding the secret key. It defaults to FLOW_SECRET_KEY.
+	SecretKeyVar string
+
+	// PreviousSecretKeysVar is the variable holding the comma separated previous secret keys. It defaults to
+	// FLOW_PREVIOUS_SECRET_KEYS.
+	PreviousSecretKeysVar string
+}
+
+// Credentials reads the credentials from the environment. It fails if the API key or the secret key aren't set.
+func (ec EnvCredentials) Credentials() (Credentials, error) {
+	apiKeyVar := orDefault(ec.APIKeyVar, "FLOW_API_KEY")
+	secretKeyVar := orDefault(ec.SecretKeyVar, "FLOW_SECRET_KEY")
+	previousVar := orDefault(ec.PreviousSecretKeysVar, "FLOW_PREVIOUS_SECRET_KEYS")
+
+	creds := Credentials{
+		APIKey:    os.Getenv(apiKeyVar),
+		SecretKey: os.Getenv(secretKeyVar),
+	}
+
+	if creds.APIKey == "" || creds.SecretKey == "" {
+		return Credentials{}, errors.Errorf("%s and %s must be set", apiKeyVar, secretKeyVar)
+	}
+
+	for _, key := range strings.Split(os.Getenv(previousVar), ",") {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			creds.PreviousSecretKeys = append(creds.PreviousSecretKeys, key)
+		}
+	}
+
+	return creds, nil
+}
+
+// FileCredentials is a CredentialsProvider that reads the credentials from a JSON file, with the same fields as
+// Credentials. The file is read again whenever its modification time changes.
+type FileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	creds   Credentials
+}
+
+// NewFileCredentials creates a *FileCredentials that reads the file at path.
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{
+		path: path,
+	}
+}
+
+// Credentials returns the credentials in the file, reading it again if it changed since the last call.
+func (fc *FileCredentials) Credentials() (Credentials, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	info, err := os.Stat(fc.path)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to read credentials file")
+	}
+
+	if info.ModTime().Equal(fc.modTime) {
+		return fc.creds, nil
+	}
+
+	data, err := ioutil.ReadFile(fc.path)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to read credentials file")
+	}
+
+	var creds Credentials
+	err = jsoniter.Unmarshal(data, &creds)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to parse credentials file")
+	}
+
+	if creds.APIKey == "" || creds.SecretKey == "" {
+		return Credentials{}, errors.New("invalid credentials file: apiKey and secretKey must be set")
+	}
+
+	fc.creds = creds
+	fc.modTime = info.ModTime()
+	return creds, nil
+}
+
+// RotatingCredentials is a CredentialsProvider whose credentials can be replaced at runtime. The secret keys replaced
+// are still accepted when verifying signatures until their grace period ends.
+type RotatingCredentials struct {
+	mu       sync.Mutex
+	current  Credentials
+	previous map[string]time.Time
+}
+
+// NewRotatingCredentials creates a *RotatingCredentials that starts with the given credentials.
+func NewRotatingCredentials(initial Credentials) *RotatingCredentials {
+	return &RotatingCredentials{
+		current:  initial,
+		previous: make(map[string]time.Time),
+	}
+}
+
+// Rotate replaces the credentials. The secret key being replaced is accepted when verifying signatures for the grace
+// period.
+func (rc *RotatingCredentials) Rotate(next Credentials, grace time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.current.SecretKey != next.SecretKey && grace > 0 {
+		rc.previous[rc.current.SecretKey] = time.Now().Add(grace)
+	}
+
+	rc.current = next
+}
+
+// Credentials returns the current credentials, along with the previous secret keys whose grace period didn't end.
+func (rc *RotatingCredentials) Credentials() (Credentials, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	creds := rc.current
+	creds.PreviousSecretKeys = append([]string(nil), rc.current.PreviousSecretKeys...)
+
+	now := time.Now()
+	for key, expiry := range rc.previous {
+		if now.After(expiry) {
+			delete(rc.previous, key)
+			continue
+		}
+
+		creds.PreviousSecretKeys = append(creds.PreviousSecretKeys, key)
+	}
+
+	return creds, nil
+}
+
+// credentials returns the credentials of the client, from its Credentials provider if set or from its APIKey and
+// SecretKey otherwise.
+func (c Client) credentials() (Credentials, error) {
+	if c.Credentials == nil {
+		return Credentials{
+			APIKey:    c.APIKey,
+			SecretKey: c.SecretKey,
+		}, nil
+	}
+
+	creds, err := c.Credentials.Credentials()
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to load credentials")
+	}
+
+	return creds, nil
+}
+
+// orDefault returns value, or def if value is empty.
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+
+	return value
+}
@@ -0,0 +1,144 @@
+package flow
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRotatingCredentials checks that signatures made with a rotated secret key are accepted until its grace period
+// ends, and that new signatures are made with the current key.
+func TestRotatingCredentials(t *testing.T) {
+	params := map[string]interface{}{"token": "abc"}
+	signatures := make(map[string]string)
+	for _, secret := range []string{"first", "second", "third"} {
+		signatures[secret] = signParams(unsignedParams(params), secret)
+	}
+
+	creds := NewRotatingCredentials(Credentials{APIKey: "key", SecretKey: "first"})
+	c := NewClient("", "")
+	c.Credentials = creds
+
+	creds.Rotate(Credentials{APIKey: "key", SecretKey: "second"}, 30*time.Millisecond)
+	creds.Rotate(Credentials{APIKey: "key", SecretKey: "third"}, time.Hour)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  map[string]bool
+	}{
+		{
+			name: "during the grace periods",
+			want: map[string]bool{"first": true, "second": true, "third": true},
+		},
+		{
+			name:  "after the first grace period",
+			after: 40 * time.Millisecond,
+			want:  map[string]bool{"first": false, "second": true, "third": true},
+		},
+	}
+
+	for _, test := range tests {
+		time.Sleep(test.after)
+
+		for secret, want := range test.want {
+			if got := c.Verify(params, signatures[secret]); got != want {
+				t.Errorf("%s: Verify() of a %s key signature = %v, want %v", test.name, secret, got, want)
+			}
+		}
+	}
+
+	signature, err := c.Sign(params)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if signature != signatures["third"] {
+		t.Fatalf("Sign() = %q, want the signature of the current key %q", signature, signatures["third"])
+	}
+}
+
+// TestRotatingCredentialsWithoutGrace checks that a key rotated without a grace period is rejected right away.
+func TestRotatingCredentialsWithoutGrace(t *testing.T) {
+	params := map[string]interface{}{"token": "abc"}
+
+	creds := NewRotatingCredentials(Credentials{APIKey: "key", SecretKey: "first"})
+	c := NewClient("", "")
+	c.Credentials = creds
+
+	creds.Rotate(Credentials{APIKey: "key", SecretKey: "second"}, 0)
+
+	if c.Verify(params, signParams(unsignedParams(params), "first")) {
+		t.Fatalf("Verify() of a signature of a key rotated without grace = true, want false")
+	}
+}
+
+// TestFileCredentials checks that the credentials file is read again when it changes, and that its previous secret keys
+// are accepted.
+func TestFileCredentials(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.json")
+	write := func(data string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("unable to write credentials file: %v", err)
+		}
+		_ = os.Chtimes(path, modTime, modTime)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Credentials
+		wantErr bool
+	}{
+		{
+			name: "initial",
+			data: `{"apiKey": "key", "secretKey": "first"}`,
+			want: Credentials{APIKey: "key", SecretKey: "first"},
+		},
+		{
+			name: "rotated",
+			data: `{"apiKey": "key", "secretKey": "second", "previousSecretKeys": ["first"]}`,
+			want: Credentials{APIKey: "key", SecretKey: "second", PreviousSecretKeys: []string{"first"}},
+		},
+		{
+			name:    "incomplete",
+			data:    `{"apiKey": "key"}`,
+			wantErr: true,
+		},
+	}
+
+	fc := NewFileCredentials(path)
+	modTime := time.Now().Add(-time.Hour)
+	for _, test := range tests {
+		modTime = modTime.Add(time.Second)
+		write(test.data, modTime)
+
+		got, err := fc.Credentials()
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: Credentials() error = %v, want error %v", test.name, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		if got.APIKey != test.want.APIKey || got.SecretKey != test.want.SecretKey ||
+			len(got.PreviousSecretKeys) != len(test.want.PreviousSecretKeys) {
+			t.Errorf("%s: Credentials() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestSignWithoutCredentials checks that signing fails when the credentials can't be loaded, instead of signing with
+// an empty key.
+func TestSignWithoutCredentials(t *testing.T) {
+	c := NewClient("", "")
+	c.Credentials = NewFileCredentials(filepath.Join(os.TempDir(), "flow-missing-credentials.json"))
+
+	if signature, err := c.Sign(map[string]interface{}{"token": "abc"}); err == nil {
+		t.Fatalf("Sign() = %q, want an error", signature)
+	}
+}
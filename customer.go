@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RegisterStatusPending indicates that the customer didn't finish registering a card.
+	RegisterStatusPending = "0"
+
+	// RegisterStatusRegistered indicates that the customer registered a card successfully.
+	RegisterStatusRegistered = "1"
+)
+
+// RegisterStatus is the result of a customer's card registration.
+type RegisterStatus struct {
+	// Status is the status of the registration. It might be one of:
+	//  0 Pending    - RegisterStatusPending
+	//  1 Registered - RegisterStatusRegistered
+	Status string `json:"status"`
+
+	// CustomerID is the Flow identifier of the customer.
+	CustomerID string `json:"customerId"`
+
+	// CreditCardType is the brand of the registered card.
+	CreditCardType string `json:"creditCardType"`
+
+	// Last4CardDigits are the last four digits of the registered card.
+	Last4CardDigits string `json:"last4CardDigits"`
+}
+
+// GetRegisterStatus fetches the result of a customer's card registration based on the provided token.
+func (c Client) GetRegisterStatus(token string) (*RegisterStatus, error) {
+	return c.GetRegisterStatusContext(context.Background(), token)
+}
+
+// GetRegisterStatusContext is like GetRegisterStatus, but the request is bound to ctx.
+func (c Client) GetRegisterStatusContext(ctx context.Context, token string) (*RegisterStatus, error) {
+	url, err := c.buildGET("/customer/getRegisterStatus", map[string]interface{}{
+		"token": token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to transact with the server")
+	}
+
+	var status RegisterStatus
+	err = jsoniter.Unmarshal(data, &status)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse response")
+	}
+
+	return &status, err
+}
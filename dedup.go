@@ -0,0 +1,486 @@
+package flow
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ClaimState is the state of an idempotency key found by IdempotencyStore.Claim.
+type ClaimState int
+
+const (
+	// ClaimNew means the key was claimed by the caller, who must deliver it and then Complete or Release it.
+	ClaimNew ClaimState = iota
+
+	// ClaimInFlight means the key is claimed by another delivery that hasn't finished yet.
+	ClaimInFlight
+
+	// ClaimDelivered means the key was already delivered.
+	ClaimDelivered
+)
+
+// DefaultClaimLease is the time a claimed key is considered to be in delivery when the Client's ClaimLease is zero.
+const DefaultClaimLease = 5 * time.Minute
+
+// IdempotencyStore keeps track of the callbacks already delivered, so the confirmation handlers call the user code
+// only once per order or refund status even if Flow sends the same confirmation more than once. Implementations must be safe
+// for concurrent use, and Claim must be atomic across every process sharing the store.
+type IdempotencyStore interface {
+	// Claim marks the key as being delivered for up to lease. It returns ClaimNew if the key was claimed, ClaimInFlight
+	// if it's claimed by another delivery, or ClaimDelivered if it was already delivered. A claim older than lease is
+	// considered abandoned, for example by a process that crashed, and can be claimed again.
+	Claim(key string, lease time.Duration) (ClaimState, error)
+
+	// Complete marks a claimed key as delivered.
+	Complete(key string) error
+
+	// Release removes the claim over the key, so a later delivery can claim it again.
+	Release(key string) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps the keys in memory. It only deduplicates the deliveries
+// received by a single process. Keys are kept until they're removed by Prune, which long-running services should
+// call periodically.
+type MemoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]memoryKey
+}
+
+// memoryKey is the state of a key in a MemoryIdempotencyStore.
+type memoryKey struct {
+	delivered bool
+	updated   time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty *MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		keys: make(map[string]memoryKey),
+	}
+}
+
+// Claim marks the key as being delivered for up to lease, and returns the state the key was in.
+func (s *MemoryIdempotencyStore) Claim(key string, lease time.Duration) (ClaimState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if state, set := s.keys[key]; set {
+		if state.delivered {
+			return ClaimDelivered, nil
+		}
+		if now.Sub(state.updated) < lease {
+			return ClaimInFlight, nil
+		}
+	}
+
+	s.keys[key] = memoryKey{updated: now}
+	return ClaimNew, nil
+}
+
+// Complete marks a claimed key as delivered.
+func (s *MemoryIdempotencyStore) Complete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = memoryKey{delivered: true, updated: time.Now()}
+	return nil
+}
+
+// Release removes the claim over the key.
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.keys[key].delivered {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// Prune removes the keys claimed or delivered more than maxAge ago, and returns how many were removed. A delivery
+// repeated by Flow after its key is pruned is processed again, so maxAge should be longer than the time Flow keeps
+// retrying a confirmation.
+func (s *MemoryIdempotencyStore) Prune(maxAge time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := time.Now().Add(-maxAge)
+	removed := 0
+	for key, state := range s.keys {
+		if state.updated.Before(limit) {
+			delete(s.keys, key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// FileIdempotencyStore is an IdempotencyStore that keeps one file per key inside Dir. Claims are made by exclusively
+// creating the file, and abandoned claims are taken over while holding an exclusively created lock file, so the store
+// can be shared by every process with access to the directory. Files are kept until they're removed by Prune, which
+// long-running services should call periodically.
+type FileIdempotencyStore struct {
+	// Dir is the directory where the keys are stored. It must exist.
+	Dir string
+}
+
+// Claim marks the key as being delivered for up to lease, and returns the state the key was in.
+func (s FileIdempotencyStore) Claim(key string, lease time.Duration) (ClaimState, error) {
+	created, err := s.create(s.path(key))
+	if err != nil || created {
+		return ClaimNew, err
+	}
+
+	state, stale, err := s.state(key, lease)
+	if err != nil || state != ClaimInFlight || !stale {
+		return state, err
+	}
+
+	// The claim was abandoned. It's taken over while holding the lock, checking again that it's still abandoned, so a
+	// single process can take it.
+	locked, err := s.create(s.path(key) + ".lock")
+	if err != nil {
+		return ClaimInFlight, err
+	}
+	if !locked {
+		s.removeStaleLock(key, lease)
+		return ClaimInFlight, nil
+	}
+	defer os.Remove(s.path(key) + ".lock")
+
+	state, stale, err = s.state(key, lease)
+	if err != nil || state != ClaimInFlight || !stale {
+		return state, err
+	}
+
+	err = s.write(key, "claimed")
+	if err != nil {
+		return ClaimInFlight, errors.Wrap(err, "unable to claim key")
+	}
+
+	return ClaimNew, nil
+}
+
+// create exclusively creates a file marked as claimed. It returns false if the file already exists. The file is
+// written as a temporary file and then linked to path, which fails if path exists, so it's never seen partially
+// written.
+func (s FileIdempotencyStore) create(path string) (bool, error) {
+	f, err := ioutil.TempFile(s.Dir, tempPrefix)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim key")
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("claimed")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim key")
+	}
+
+	err = os.Link(f.Name(), path)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim key")
+	}
+
+	return true, nil
+}
+
+// state reads the state of an existing key, and whether its claim is older than lease.
+func (s FileIdempotencyStore) state(key string, lease time.Duration) (ClaimState, bool, error) {
+	info, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		// The claim was released since it was found, it's left for the next delivery to claim.
+		return ClaimInFlight, false, nil
+	}
+	if err != nil {
+		return ClaimInFlight, false, errors.Wrap(err, "unable to claim key")
+	}
+
+	state, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return ClaimInFlight, false, nil
+	}
+	if err != nil {
+		return ClaimInFlight, false, errors.Wrap(err, "unable to claim key")
+	}
+
+	if string(state) == "delivered" {
+		return ClaimDelivered, false, nil
+	}
+
+	return ClaimInFlight, time.Since(info.ModTime()) >= lease, nil
+}
+
+// removeStaleLock removes the lock of a key left by a process that stopped while taking over a claim.
+func (s FileIdempotencyStore) removeStaleLock(key string, lease time.Duration) {
+	info, err := os.Stat(s.path(key) + ".lock")
+	if err == nil && time.Since(info.ModTime()) >= lease {
+		_ = os.Remove(s.path(key) + ".lock")
+	}
+}
+
+// Complete marks a claimed key as delivered.
+func (s FileIdempotencyStore) Complete(key string) error {
+	err := s.write(key, "delivered")
+	if err != nil {
+		return errors.Wrap(err, "unable to complete key")
+	}
+
+	return nil
+}
+
+// write replaces the state of a key. The state is written to a temporary file that is then renamed over the key's
+// file, so the key is never seen with a partially written state.
+func (s FileIdempotencyStore) write(key, state string) error {
+	f, err := ioutil.TempFile(s.Dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(state)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Release removes the claim over the key.
+func (s FileIdempotencyStore) Release(key string) error {
+	state, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to release key")
+	}
+
+	if string(state) == "delivered" {
+		return nil
+	}
+
+	err = os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to release key")
+	}
+
+	return nil
+}
+
+// Prune removes the keys claimed or delivered more than maxAge ago, and returns how many were removed. Only the files
+// named like keys are considered, along with the temporary files left by processes that stopped while writing one. A
+// delivery repeated by Flow after its key is pruned is processed again, so maxAge
+// should be longer than the time Flow keeps retrying a confirmation.
+func (s FileIdempotencyStore) Prune(maxAge time.Duration) (int, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to prune keys")
+	}
+
+	limit := time.Now().Add(-maxAge)
+	removed := 0
+	for _, file := range files {
+		if file.IsDir() || !file.ModTime().Before(limit) {
+			continue
+		}
+
+		temporary := strings.HasPrefix(file.Name(), tempPrefix)
+		if _, err := hex.DecodeString(file.Name()); err != nil && !temporary {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(s.Dir, file.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return removed, errors.Wrap(err, "unable to prune keys")
+		}
+		if !temporary {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// tempPrefix is the prefix of the temporary files written by a FileIdempotencyStore.
+const tempPrefix = ".tmp-"
+
+// path returns the file used for the key. The key is hex encoded so it's always a valid file name.
+func (s FileIdempotencyStore) path(key string) string {
+	return filepath.Join(s.Dir, hex.EncodeToString([]byte(key)))
+}
+
+// SQLIdempotencyStore is an IdempotencyStore backed by a SQL table. The table must have a unique text column named
+// idempotency_key, a boolean column named delivered and an integer column named claimed_at, which holds the Unix time
+// of the last claim, for example:
+//
+//	CREATE TABLE flow_callbacks (
+//	    idempotency_key VARCHAR(255) PRIMARY KEY,
+//	    delivered       BOOLEAN NOT NULL DEFAULT FALSE,
+//	    claimed_at      BIGINT NOT NULL
+//	);
+//
+// Rows are never deleted by the store once delivered. To bound the table, periodically delete the rows with a
+// claimed_at older than the time Flow keeps retrying a confirmation.
+type SQLIdempotencyStore struct {
+	// DB is the database holding the table.
+	DB *sql.DB
+
+	// Table is the name of the table.
+	Table string
+
+	// Placeholder returns the bind parameter for the nth (starting at 1) argument of a query. It defaults to "?",
+	// drivers like PostgreSQL need it to return "$1", "$2" and so on.
+	Placeholder func(n int) string
+}
+
+// Claim marks the key as being delivered for up to lease, and returns the state the key was in.
+func (s SQLIdempotencyStore) Claim(key string, lease time.Duration) (ClaimState, error) {
+	now := time.Now()
+	_, insertErr := s.DB.Exec(fmt.Sprintf("INSERT INTO %s (idempotency_key, delivered, claimed_at) VALUES (%s, %s, %s)",
+		s.Table, s.placeholder(1), s.placeholder(2), s.placeholder(3)), key, false, now.Unix())
+	if insertErr == nil {
+		return ClaimNew, nil
+	}
+
+	// The insert might have failed because of the unique constraint or for any other reason, as the error depends on
+	// the driver. An abandoned claim is taken over by the single update that finds it abandoned.
+	result, err := s.DB.Exec(fmt.Sprintf("UPDATE %s SET claimed_at = %s WHERE idempotency_key = %s AND delivered = %s "+
+		"AND claimed_at <= %s", s.Table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4)),
+		now.Unix(), key, false, now.Add(-lease).Unix())
+	if err != nil {
+		return ClaimInFlight, errors.Wrap(err, "unable to claim key")
+	}
+
+	taken, err := result.RowsAffected()
+	if err != nil {
+		return ClaimInFlight, errors.Wrap(err, "unable to claim key")
+	}
+	if taken > 0 {
+		return ClaimNew, nil
+	}
+
+	var delivered bool
+	err = s.DB.QueryRow(fmt.Sprintf("SELECT delivered FROM %s WHERE idempotency_key = %s", s.Table, s.placeholder(1)),
+		key).Scan(&delivered)
+	if err == sql.ErrNoRows {
+		return ClaimInFlight, errors.Wrap(insertErr, "unable to claim key")
+	}
+	if err != nil {
+		return ClaimInFlight, errors.Wrap(err, "unable to claim key")
+	}
+
+	if delivered {
+		return ClaimDelivered, nil
+	}
+
+	return ClaimInFlight, nil
+}
+
+// Complete marks a claimed key as delivered.
+func (s SQLIdempotencyStore) Complete(key string) error {
+	_, err := s.DB.Exec(fmt.Sprintf("UPDATE %s SET delivered = %s WHERE idempotency_key = %s",
+		s.Table, s.placeholder(1), s.placeholder(2)), true, key)
+	if err != nil {
+		return errors.Wrap(err, "unable to complete key")
+	}
+
+	return nil
+}
+
+// Release removes the claim over the key.
+func (s SQLIdempotencyStore) Release(key string) error {
+	_, err := s.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE idempotency_key = %s AND delivered = %s",
+		s.Table, s.placeholder(1), s.placeholder(2)), key, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to release key")
+	}
+
+	return nil
+}
+
+// placeholder returns the bind parameter for the nth argument of a query.
+func (s SQLIdempotencyStore) placeholder(n int) string {
+	if s.Placeholder == nil {
+		return "?"
+	}
+
+	return s.Placeholder(n)
+}
+
+// claim claims the key on the client's IdempotencyStore for the client's ClaimLease. The callback should only be
+// delivered if the key is new.
+func (c *Client) claim(key string) (ClaimState, error) {
+	if c.IdempotencyStore == nil {
+		return ClaimNew, nil
+	}
+
+	lease := c.ClaimLease
+	if lease <= 0 {
+		lease = DefaultClaimLease
+	}
+
+	return c.IdempotencyStore.Claim(key, lease)
+}
+
+// deliver runs fn for a claimed key, marking the key as delivered if fn succeeds or releasing it if fn fails or
+// panics, so a later delivery can retry it.
+func (c *Client) deliver(key string, fn func() error) (err error) {
+	delivered := false
+	defer func() {
+		if !delivered {
+			_ = c.finish(key, errors.New("delivery panicked"))
+		}
+	}()
+
+	err = fn()
+	delivered = true
+	return c.finish(key, err)
+}
+
+// finish marks a claimed key as delivered if err is nil, or releases it so a later delivery can retry it. It returns
+// err, or the error marking the key as delivered.
+func (c *Client) finish(key string, err error) error {
+	if c.IdempotencyStore == nil {
+		return err
+	}
+
+	if err != nil {
+		_ = c.IdempotencyStore.Release(key)
+		return err
+	}
+
+	return c.IdempotencyStore.Complete(key)
+}
+
+// orderKey is the idempotency key of an order on its current status.
+func orderKey(order *Order) string {
+	return fmt.Sprintf("order-%d-%d", order.FlowOrder, order.Status)
+}
+
+// refundKey is the idempotency key of a refund on its current status.
+func refundKey(refund *RefundStatus) string {
+	return fmt.Sprintf("refund-%s-%s", refund.RefundOrder, refund.Status)
+}
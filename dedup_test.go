@@ -0,0 +1,177 @@
+package flow
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestIdempotencyStores checks the claims of each IdempotencyStore through the life of a key.
+func TestIdempotencyStores(t *testing.T) {
+	type step struct {
+		op    string
+		lease time.Duration
+		want  ClaimState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "new key",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+			},
+		},
+		{
+			name: "claimed key",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+				{op: "claim", lease: time.Hour, want: ClaimInFlight},
+			},
+		},
+		{
+			name: "delivered key",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+				{op: "complete"},
+				{op: "claim", lease: time.Hour, want: ClaimDelivered},
+				{op: "claim", lease: 0, want: ClaimDelivered},
+			},
+		},
+		{
+			name: "released key",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+				{op: "release"},
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+			},
+		},
+		{
+			name: "delivered key isn't released",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+				{op: "complete"},
+				{op: "release"},
+				{op: "claim", lease: time.Hour, want: ClaimDelivered},
+			},
+		},
+		{
+			name: "abandoned claim",
+			steps: []step{
+				{op: "claim", lease: time.Hour, want: ClaimNew},
+				{op: "claim", lease: 0, want: ClaimNew},
+				{op: "claim", lease: time.Hour, want: ClaimInFlight},
+			},
+		},
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	stores := []struct {
+		name  string
+		store IdempotencyStore
+	}{
+		{name: "memory", store: NewMemoryIdempotencyStore()},
+		{name: "file", store: FileIdempotencyStore{Dir: dir}},
+	}
+
+	for _, store := range stores {
+		for _, test := range tests {
+			key := store.name + " " + test.name
+			for i, step := range test.steps {
+				var err error
+				switch step.op {
+				case "claim":
+					var state ClaimState
+					state, err = store.store.Claim(key, step.lease)
+					if err == nil && state != step.want {
+						t.Errorf("%s: step %d: Claim() = %v, want %v", key, i, state, step.want)
+					}
+				case "complete":
+					err = store.store.Complete(key)
+				case "release":
+					err = store.store.Release(key)
+				}
+				if err != nil {
+					t.Fatalf("%s: step %d: %s failed: %v", key, i, step.op, err)
+				}
+			}
+		}
+	}
+}
+
+// TestMemoryIdempotencyStorePrune checks that only the old keys are pruned.
+func TestMemoryIdempotencyStorePrune(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	_, _ = store.Claim("old", time.Hour)
+	_ = store.Complete("recent")
+	store.keys["old"] = memoryKey{updated: time.Now().Add(-2 * time.Hour)}
+
+	if removed := store.Prune(time.Hour); removed != 1 {
+		t.Fatalf("Prune() = %d, want 1", removed)
+	}
+	if state, _ := store.Claim("recent", time.Hour); state != ClaimDelivered {
+		t.Fatalf("Claim() of a recent key = %v, want %v", state, ClaimDelivered)
+	}
+	if state, _ := store.Claim("old", time.Hour); state != ClaimNew {
+		t.Fatalf("Claim() of a pruned key = %v, want %v", state, ClaimNew)
+	}
+}
+
+// TestFileIdempotencyStorePrune checks that only the old keys and temporary files are pruned, leaving the other files
+// of the directory alone.
+func TestFileIdempotencyStorePrune(t *testing.T) {
+	store := FileIdempotencyStore{Dir: tempDir(t)}
+	defer os.RemoveAll(store.Dir)
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	_, _ = store.Claim("old", time.Hour)
+	_ = store.Complete("recent")
+
+	files := map[string]bool{
+		store.path("old"):                          false,
+		store.path("recent"):                       true,
+		filepath.Join(store.Dir, tempPrefix+"1"):   false,
+		filepath.Join(store.Dir, "unrelated.json"): true,
+	}
+	for path := range files {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			_ = ioutil.WriteFile(path, nil, 0600)
+		}
+		if path != store.path("recent") {
+			_ = os.Chtimes(path, old, old)
+		}
+	}
+
+	removed, err := store.Prune(time.Hour)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Prune() = %d, want 1", removed)
+	}
+
+	for path, kept := range files {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("file %s kept = %v, want %v", filepath.Base(path), err == nil, kept)
+		}
+	}
+}
+
+// tempDir creates a temporary directory, which the caller must remove.
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "flow-test")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+
+	return dir
+}
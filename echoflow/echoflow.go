@@ -0,0 +1,33 @@
+// Package echoflow adapts the Flow confirmation callbacks to the Echo framework.
+package echoflow
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/labstack/echo/v4"
+)
+
+// OrderCallbacks returns an echo.HandlerFunc that processes the Flow order confirmation request.
+// It validates the provided token and calls the function of callbacks matching the order's status.
+func OrderCallbacks(c *flow.Client, callbacks flow.OrderCallbacks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.NoContent(c.ConfirmOrder(ctx.Request().Context(), token(ctx), callbacks))
+	}
+}
+
+// RefundCallbacks returns an echo.HandlerFunc that processes the Flow refund confirmation request.
+// It validates the provided token and calls the function of callbacks matching the refund's status.
+func RefundCallbacks(c *flow.Client, callbacks flow.RefundCallbacks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.NoContent(c.ConfirmRefund(ctx.Request().Context(), token(ctx), callbacks))
+	}
+}
+
+// token reads the token of a callback request with flow.CallbackToken, like the net/http handlers do.
+func token(ctx echo.Context) string {
+	err := ctx.Request().ParseForm()
+	if err != nil {
+		return ""
+	}
+
+	return flow.CallbackToken(ctx.Request().Form)
+}
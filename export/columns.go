@@ -0,0 +1,133 @@
+package export
+
+import (
+	"strconv"
+
+	"github.com/CamiloHernandez/go-flow"
+)
+
+// OrderColumns returns the CSV columns of an order.
+func OrderColumns() []string {
+	return append([]string(nil), orderColumns...)
+}
+
+// orderColumns are the CSV columns of an order.
+var orderColumns = []string{
+	"flow_order",
+	"commerce_order",
+	"request_date",
+	"status",
+	"subject",
+	"currency",
+	"amount",
+	"payer_email",
+	"optional_rut",
+	"optional_id",
+	"pending_media",
+	"pending_date",
+	"payment_date",
+	"payment_media",
+	"payment_conversion_date",
+	"payment_conversion_rate",
+	"payment_amount",
+	"payment_currency",
+	"payment_fee",
+	"payment_balance",
+	"payment_transfer_date",
+	"merchant_id",
+}
+
+// OrderRow returns the values of an order, in the order of OrderColumns.
+func OrderRow(o flow.Order) []string {
+	return []string{
+		strconv.Itoa(o.FlowOrder),
+		o.CommerceOrder,
+		o.RequestDate,
+		strconv.Itoa(o.Status),
+		o.Subject,
+		o.Currency,
+		o.Amount,
+		o.PayerEmail,
+		o.Optional.RUT,
+		o.Optional.ID,
+		o.PendingInfo.Media,
+		o.PendingInfo.Date,
+		o.PaymentData.Date,
+		o.PaymentData.Media,
+		o.PaymentData.ConversionDate,
+		formatFloat(o.PaymentData.ConversionRate),
+		o.PaymentData.Amount,
+		o.PaymentData.Currency,
+		o.PaymentData.Fee,
+		strconv.FormatInt(o.PaymentData.Balance, 10),
+		o.PaymentData.TransferDate,
+		o.MerchantID,
+	}
+}
+
+// RefundColumns returns the CSV columns of a refund.
+func RefundColumns() []string {
+	return append([]string(nil), refundColumns...)
+}
+
+// refundColumns are the CSV columns of a refund.
+var refundColumns = []string{
+	"token",
+	"flow_refund_order",
+	"date",
+	"status",
+	"amount",
+	"fee",
+}
+
+// RefundRow returns the values of a refund, in the order of RefundColumns.
+func RefundRow(r flow.RefundStatus) []string {
+	return []string{
+		r.Token,
+		r.RefundOrder,
+		r.Date,
+		r.Status,
+		strconv.FormatUint(r.Amount, 10),
+		strconv.FormatUint(r.Fee, 10),
+	}
+}
+
+// SettlementColumns returns the CSV columns of a settlement.
+func SettlementColumns() []string {
+	return append([]string(nil), settlementColumns...)
+}
+
+// settlementColumns are the CSV columns of a settlement.
+var settlementColumns = []string{
+	"id",
+	"date",
+	"tax_id",
+	"name",
+	"email",
+	"currency",
+	"initial_balance",
+	"final_balance",
+	"transferred",
+	"billed",
+}
+
+// SettlementRow returns the values of a settlement, in the order of SettlementColumns.
+func SettlementRow(s flow.Settlement) []string {
+	return []string{
+		strconv.Itoa(s.ID),
+		s.Date,
+		s.TaxID,
+		s.Name,
+		s.Email,
+		s.Currency,
+		formatFloat(s.InitialBalance),
+		formatFloat(s.FinalBalance),
+		formatFloat(s.Transferred),
+		formatFloat(s.Billed),
+	}
+}
+
+// formatFloat writes a float in decimal notation with the minimum number of digits.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,162 @@
+// Package export writes Flow orders, refunds and settlements as CSV or newline-delimited JSON, for spreadsheets and
+// accounting tools.
+//
+// The CSV columns are stable: nested values like the PaymentData and Optional of an order are flattened into their
+// own columns, and the header is always written, even if there are no records. Text values starting like a
+// spreadsheet formula, with "=", "+", "-" or "@", are prefixed with a single quote so spreadsheets show them as text.
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	// CSV writes a header row followed by a row per record.
+	CSV Format = "csv"
+
+	// NDJSON writes a JSON object per line.
+	NDJSON Format = "ndjson"
+)
+
+// Writer streams records of a single kind. It's not safe for concurrent use.
+type Writer struct {
+	format  Format
+	columns []string
+	row     func(record interface{}) ([]string, bool)
+
+	csv     *csv.Writer
+	json    *jsoniter.Encoder
+	started bool
+}
+
+// NewOrderWriter creates a *Writer of flow.Order records, with the columns in OrderColumns.
+func NewOrderWriter(w io.Writer, format Format) (*Writer, error) {
+	return newWriter(w, format, orderColumns, func(record interface{}) ([]string, bool) {
+		switch order := record.(type) {
+		case flow.Order:
+			return OrderRow(order), true
+		case *flow.Order:
+			return OrderRow(*order), true
+		}
+		return nil, false
+	})
+}
+
+// NewRefundWriter creates a *Writer of flow.RefundStatus records, with the columns in RefundColumns.
+func NewRefundWriter(w io.Writer, format Format) (*Writer, error) {
+	return newWriter(w, format, refundColumns, func(record interface{}) ([]string, bool) {
+		switch refund := record.(type) {
+		case flow.RefundStatus:
+			return RefundRow(refund), true
+		case *flow.RefundStatus:
+			return RefundRow(*refund), true
+		}
+		return nil, false
+	})
+}
+
+// NewSettlementWriter creates a *Writer of flow.Settlement records, with the columns in SettlementColumns.
+func NewSettlementWriter(w io.Writer, format Format) (*Writer, error) {
+	return newWriter(w, format, settlementColumns, func(record interface{}) ([]string, bool) {
+		switch settlement := record.(type) {
+		case flow.Settlement:
+			return SettlementRow(settlement), true
+		case *flow.Settlement:
+			return SettlementRow(*settlement), true
+		}
+		return nil, false
+	})
+}
+
+// newWriter creates a *Writer in the given format.
+func newWriter(w io.Writer, format Format, columns []string,
+	row func(record interface{}) ([]string, bool)) (*Writer, error) {
+	ew := &Writer{
+		format:  format,
+		columns: columns,
+		row:     row,
+	}
+
+	switch format {
+	case CSV:
+		ew.csv = csv.NewWriter(w)
+	case NDJSON:
+		ew.json = jsoniter.NewEncoder(w)
+	default:
+		return nil, errors.Errorf("unknown export format %q", format)
+	}
+
+	return ew, nil
+}
+
+// Write writes a record, that must be of the kind of the Writer, either as a value or a pointer.
+func (ew *Writer) Write(record interface{}) error {
+	row, ok := ew.row(record)
+	if !ok {
+		return errors.Errorf("unable to export a %T with this writer", record)
+	}
+
+	if ew.json != nil {
+		return errors.Wrap(ew.json.Encode(record), "unable to write record")
+	}
+
+	err := ew.header()
+	if err != nil {
+		return err
+	}
+
+	for i, value := range row {
+		row[i] = escapeFormula(value)
+	}
+
+	return errors.Wrap(ew.csv.Write(row), "unable to write record")
+}
+
+// escapeFormula prefixes a value with a single quote if a spreadsheet would take it for a formula. Numbers, like
+// negative amounts, are kept as they are.
+func escapeFormula(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@", rune(value[0])) {
+		return value
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	return "'" + value
+}
+
+// Flush writes any buffered data, and the CSV header if no records were written.
+func (ew *Writer) Flush() error {
+	if ew.csv == nil {
+		return nil
+	}
+
+	err := ew.header()
+	if err != nil {
+		return err
+	}
+
+	ew.csv.Flush()
+	return errors.Wrap(ew.csv.Error(), "unable to write records")
+}
+
+// header writes the CSV header, if it wasn't written yet.
+func (ew *Writer) header() error {
+	if ew.started {
+		return nil
+	}
+
+	ew.started = true
+	return errors.Wrap(ew.csv.Write(ew.columns), "unable to write header")
+}
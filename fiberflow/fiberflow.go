@@ -0,0 +1,38 @@
+// Package fiberflow adapts the Flow confirmation callbacks to the Fiber framework.
+package fiberflow
+
+import (
+	"net/url"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/gofiber/fiber/v2"
+)
+
+// OrderCallbacks returns a fiber.Handler that processes the Flow order confirmation request.
+// It validates the provided token and calls the function of callbacks matching the order's status.
+func OrderCallbacks(c *flow.Client, callbacks flow.OrderCallbacks) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(c.ConfirmOrder(ctx.UserContext(), token(ctx), callbacks))
+	}
+}
+
+// RefundCallbacks returns a fiber.Handler that processes the Flow refund confirmation request.
+// It validates the provided token and calls the function of callbacks matching the refund's status.
+func RefundCallbacks(c *flow.Client, callbacks flow.RefundCallbacks) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(c.ConfirmRefund(ctx.UserContext(), token(ctx), callbacks))
+	}
+}
+
+// token reads the token of a callback request with flow.CallbackToken, taking the values of both its query and its
+// form encoded body like the net/http handlers do.
+func token(ctx *fiber.Ctx) string {
+	values := url.Values{}
+	add := func(key, value []byte) {
+		values.Add(string(key), string(value))
+	}
+
+	ctx.Context().QueryArgs().VisitAll(add)
+	ctx.Context().PostArgs().VisitAll(add)
+	return flow.CallbackToken(values)
+}
@@ -0,0 +1,77 @@
+// Package flowotel traces the requests and callbacks of a flow.Client with OpenTelemetry.
+package flowotel
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/CamiloHernandez/go-flow"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this package.
+const instrumentationName = "github.com/CamiloHernandez/go-flow/flowotel"
+
+var _ flow.Tracer = (*Tracer)(nil)
+
+// Tracer is a flow.Tracer that creates OpenTelemetry spans.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New creates a *Tracer that creates the spans with the given provider and propagates the trace context to Flow with
+// the global propagator. If provider is nil, the global provider is used.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Tracer{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+// Start starts a span as a child of the span in ctx, and returns a context containing the new span.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, flow.Span) {
+	ctx, s := t.tracer.Start(ctx, name)
+	return ctx, span{s}
+}
+
+// Inject adds the trace context in ctx to the headers of a request sent to Flow.
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// span adapts a trace.Span to flow.Span.
+type span struct {
+	span trace.Span
+}
+
+// SetAttribute annotates the span.
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	}
+}
+
+// SetError records the error and marks the span as failed.
+func (s span) SetError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End finishes the span.
+func (s span) End() {
+	s.span.End()
+}
@@ -0,0 +1,61 @@
+// Package flowprom exports the metrics of a flow.Client to Prometheus.
+package flowprom
+
+import (
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ flow.Metrics = (*Metrics)(nil)
+
+// Metrics is a flow.Metrics that records the measurements on Prometheus collectors:
+//
+//	flow_requests_total{endpoint, outcome}                   - Counter of requests made to the Flow API.
+//	flow_request_duration_seconds{endpoint, outcome}         - Histogram of the latency of the requests.
+//	flow_callbacks_total{kind, outcome}                      - Counter of notifications received from Flow.
+type Metrics struct {
+	requests  *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	callbacks *prometheus.CounterVec
+}
+
+// New creates a *Metrics and registers its collectors on reg.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "flow_requests_total",
+			Help: "Number of requests made to the Flow API.",
+		}, []string{"endpoint", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "flow_request_duration_seconds",
+			Help:    "Latency of the requests made to the Flow API.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint", "outcome"}),
+		callbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "flow_callbacks_total",
+			Help: "Number of notifications received from Flow.",
+		}, []string{"kind", "outcome"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.latency, m.callbacks} {
+		err := reg.Register(collector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveRequest records a request made to a Flow API endpoint.
+func (m *Metrics) ObserveRequest(endpoint, outcome string, duration time.Duration) {
+	m.requests.WithLabelValues(endpoint, outcome).Inc()
+	m.latency.WithLabelValues(endpoint, outcome).Observe(duration.Seconds())
+}
+
+// ObserveCallback records a notification received from Flow.
+func (m *Metrics) ObserveCallback(kind, outcome string) {
+	m.callbacks.WithLabelValues(kind, outcome).Inc()
+}
@@ -0,0 +1,52 @@
+// Package ginflow adapts the Flow confirmation callbacks to the gin framework. The handlers were part of the flow
+// package before, and moved here so importing flow doesn't depend on gin.
+package ginflow
+
+import (
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/gin-gonic/gin"
+)
+
+// OrderConfirmationCallback returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token, and if the token matches a accepted order, the onAccepted function gets called.
+// It replaces the former flow.Client.GinOrderConfirmationCallback.
+func OrderConfirmationCallback(c *flow.Client, onAccepted func(*flow.Order)) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPOrderConfirmationCallback(onAccepted))
+}
+
+// RefundConfirmationCallback returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token, and if the token matches an accepted refund, the onAccepted function gets called.
+// It replaces the former flow.Client.GinRefundConfirmationCallback.
+func RefundConfirmationCallback(c *flow.Client, onAccepted func(*flow.RefundStatus)) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPRefundConfirmationCallback(onAccepted))
+}
+
+// OrderConfirmationHandler returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token, and if the token matches a payed order, the onAccepted function gets called with
+// the request's context. The response status is written after onAccepted returns, and it's a server error if
+// onAccepted fails.
+func OrderConfirmationHandler(c *flow.Client, onAccepted flow.OrderHandlerFunc) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPOrderConfirmationHandler(onAccepted))
+}
+
+// RefundConfirmationHandler returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token, and if the token matches an accepted refund, the onAccepted function gets called
+// with the request's context. The response status is written after onAccepted returns, and it's a server error if
+// onAccepted fails.
+func RefundConfirmationHandler(c *flow.Client, onAccepted flow.RefundHandlerFunc) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPRefundConfirmationHandler(onAccepted))
+}
+
+// OrderCallbacks returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token and calls the function of callbacks matching the order's status. Orders with a
+// status without function are acknowledged and ignored.
+func OrderCallbacks(c *flow.Client, callbacks flow.OrderCallbacks) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPOrderCallbacks(callbacks))
+}
+
+// RefundCallbacks returns a gin.HandlerFunc that processes the Flow callback request.
+// It validates the provided token and calls the function of callbacks matching the refund's status. Refunds with a
+// status without function are acknowledged and ignored.
+func RefundCallbacks(c *flow.Client, callbacks flow.RefundCallbacks) gin.HandlerFunc {
+	return gin.WrapF(c.HTTPRefundCallbacks(callbacks))
+}
@@ -3,8 +3,8 @@ module github.com/CamiloHernandez/go-flow
 go 1.13
 
 require (
-	github.com/fatih/structs v1.1.0
-	github.com/gin-gonic/gin v1.6.3
-	github.com/json-iterator/go v1.1.10
+	github.com/json-iterator/go v1.1.12
 	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,242 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOrderInFlight is returned by CreateOrderIdempotent when another creation for the same CommerceOrder hasn't
+// finished yet.
+var ErrOrderInFlight = errors.New("an order with the same commerce order is already being created")
+
+// OrderStore remembers the orders created through CreateOrderIdempotent, keyed by their CommerceOrder. Implementations
+// must be safe for concurrent use.
+type OrderStore interface {
+	// Load returns the response remembered for the commerce order, or nil if there is none.
+	Load(commerceOrder string) (*OrderResponse, error)
+
+	// Reserve marks the commerce order as in-flight. It must return ErrOrderInFlight if it's already reserved.
+	Reserve(commerceOrder string) error
+
+	// Save remembers the response for the commerce order and clears its in-flight mark.
+	Save(commerceOrder string, response *OrderResponse) error
+
+	// Release clears the in-flight mark of the commerce order without remembering a response.
+	Release(commerceOrder string) error
+}
+
+// MemoryOrderStore is an OrderStore that keeps the orders in memory.
+type MemoryOrderStore struct {
+	mu       sync.Mutex
+	inFlight map[string]bool
+	created  map[string]*OrderResponse
+}
+
+// NewMemoryOrderStore creates an empty *MemoryOrderStore.
+func NewMemoryOrderStore() *MemoryOrderStore {
+	return &MemoryOrderStore{
+		inFlight: make(map[string]bool),
+		created:  make(map[string]*OrderResponse),
+	}
+}
+
+// Load returns the response remembered for the commerce order, or nil if there is none.
+func (s *MemoryOrderStore) Load(commerceOrder string) (*OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.created[commerceOrder], nil
+}
+
+// Reserve marks the commerce order as in-flight.
+func (s *MemoryOrderStore) Reserve(commerceOrder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[commerceOrder] {
+		return ErrOrderInFlight
+	}
+
+	s.inFlight[commerceOrder] = true
+	return nil
+}
+
+// Save remembers the response for the commerce order and clears its in-flight mark.
+func (s *MemoryOrderStore) Save(commerceOrder string, response *OrderResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, commerceOrder)
+	s.created[commerceOrder] = response
+	return nil
+}
+
+// Release clears the in-flight mark of the commerce order.
+func (s *MemoryOrderStore) Release(commerceOrder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, commerceOrder)
+	return nil
+}
+
+// ErrOrderOutcomeUnknown is returned by CreateOrderIdempotent when the creation failed in a way that doesn't tell
+// whether Flow created the order, and looking the order up failed too. The commerce order is kept reserved in the
+// store, so retrying can't create a second order: once Flow can be reached, look the order up with
+// GetOrderByCommerceID and either Save it or Release the reservation.
+var ErrOrderOutcomeUnknown = errors.New("unable to tell whether the order was created")
+
+// RecoveredOrderError is returned by CreateOrderIdempotent when the order exists in Flow but its token is unknown,
+// because the response of its creation was lost. The payer can't be redirected to the payment page without the token.
+type RecoveredOrderError struct {
+	// FlowID is the Flow identifier of the existing order.
+	FlowID int
+}
+
+// Error describes the error.
+func (e *RecoveredOrderError) Error() string {
+	return fmt.Sprintf("order %d already exists but its token is unknown", e.FlowID)
+}
+
+// LookupTimeout is the maximum time CreateOrderIdempotent waits for the lookup of an order whose creation had an unknown
+// outcome.
+const LookupTimeout = 30 * time.Second
+
+// CreateOrderIdempotent creates a new order like CreateOrder, but never creates two orders for the same CommerceOrder.
+//
+// If the store already remembers the CommerceOrder, the stored response is returned without contacting Flow. If the
+// creation fails in a way that doesn't tell whether Flow created the order (a network error, a timeout or a server
+// error), the order is looked up with GetOrderByCommerceID:
+//   - If it exists, it's remembered and a *RecoveredOrderError is returned, as Flow doesn't report the token of an
+//     existing order.
+//   - If Flow answers with the client's OrderNotFoundCode, the reservation is released and the creation error is
+//     returned.
+//   - If the lookup fails in any other way, the reservation is kept and ErrOrderOutcomeUnknown is returned.
+func (c Client) CreateOrderIdempotent(or OrderRequest, store OrderStore) (*OrderResponse, error) {
+	return c.CreateOrderIdempotentContext(context.Background(), or, store)
+}
+
+// CreateOrderIdempotentContext is like CreateOrderIdempotent, but the creation is bound to ctx. The lookup that follows
+// an unknown outcome keeps the values of ctx but not its deadline, which is often the reason of the unknown outcome, and
+// is bound to LookupTimeout instead.
+func (c Client) CreateOrderIdempotentContext(ctx context.Context, or OrderRequest, store OrderStore) (*OrderResponse,
+	error) {
+	if !or.isValid() {
+		return nil, errors.New("invalid order request: unfilled required values")
+	}
+
+	existing, err := store.Load(or.CommerceOrder)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load order from the store")
+	}
+	if existing != nil {
+		return recovered(existing)
+	}
+
+	err = store.Reserve(or.CommerceOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	result, createErr := c.CreateOrderContext(ctx, or)
+	if createErr == nil {
+		err = store.Save(or.CommerceOrder, result)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to save order to the store")
+		}
+
+		return result, nil
+	}
+
+	if !IsAmbiguous(createErr) {
+		_ = store.Release(or.CommerceOrder)
+		return nil, createErr
+	}
+
+	lookupCtx, cancel := context.WithTimeout(detachedContext{ctx}, LookupTimeout)
+	defer cancel()
+
+	order, err := c.GetOrderByCommerceIDContext(lookupCtx, or.CommerceOrder)
+	if err != nil {
+		if !c.IsOrderNotFound(err) {
+			return nil, ErrOrderOutcomeUnknown
+		}
+
+		_ = store.Release(or.CommerceOrder)
+		return nil, createErr
+	}
+
+	result = &OrderResponse{
+		FlowID: order.FlowOrder,
+	}
+
+	err = store.Save(or.CommerceOrder, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to save order to the store")
+	}
+
+	return recovered(result)
+}
+
+// IsOrderNotFound reports whether err is Flow's answer to looking up an order that doesn't exist, identified by the
+// client's OrderNotFoundCode. It's always false if OrderNotFoundCode isn't set.
+func (c Client) IsOrderNotFound(err error) bool {
+	rqError, ok := errors.Cause(err).(*requestError)
+	return ok && c.OrderNotFoundCode != 0 && rqError.Code == c.OrderNotFoundCode &&
+		rqError.StatusCode < http.StatusInternalServerError
+}
+
+// detachedContext is a context.Context with the values of its parent, but never done.
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline reports that the context has no deadline.
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns nil, as the context is never done.
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil, as the context is never done.
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Value returns the value of the parent context for key.
+func (dc detachedContext) Value(key interface{}) interface{} {
+	return dc.parent.Value(key)
+}
+
+// recovered returns a remembered response, or a *RecoveredOrderError if its token is unknown.
+func recovered(response *OrderResponse) (*OrderResponse, error) {
+	if response.Token == "" {
+		return nil, &RecoveredOrderError{FlowID: response.FlowID}
+	}
+
+	return response, nil
+}
+
+// IsAmbiguous reports whether a failed request might still have been processed by Flow, so retrying it might repeat
+// its effect. Only errors answered by Flow with a client error status, and requests stopped by the circuit breaker,
+// are known not to have changed anything.
+func IsAmbiguous(err error) bool {
+	if errors.Cause(err) == ErrCircuitOpen {
+		return false
+	}
+
+	rqError, ok := errors.Cause(err).(*requestError)
+	if !ok {
+		return true
+	}
+
+	return rqError.StatusCode >= http.StatusInternalServerError
+}
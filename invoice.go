@@ -0,0 +1,88 @@
+package flow
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// InvoiceStatusUnpaid indicates that the invoice is still waiting to be paid.
+	InvoiceStatusUnpaid = iota
+
+	// InvoiceStatusPaid indicates that the invoice has been paid.
+	InvoiceStatusPaid
+
+	// InvoiceStatusVoided indicates that the invoice was voided.
+	InvoiceStatusVoided
+)
+
+// Invoice is a charge made to a customer, usually because of a subscription.
+type Invoice struct {
+	// ID is the Flow identifier of the invoice.
+	ID int `json:"id"`
+
+	// SubscriptionID is the identifier of the subscription that generated the invoice, if any.
+	SubscriptionID string `json:"subscriptionId"`
+
+	// CustomerID is the Flow identifier of the charged customer.
+	CustomerID string `json:"customerId"`
+
+	// Created is the date the invoice was created. It follows the format yyyy-mm-dd hh:mm:ss
+	Created string `json:"created"`
+
+	// Subject is the reason of the charge.
+	Subject string `json:"subject"`
+
+	// Currency is the currency of the invoice.
+	Currency string `json:"currency"`
+
+	// Amount is the amount of money being charged.
+	Amount float64 `json:"amount"`
+
+	// PeriodStart is the first day of the period being charged. It follows the format yyyy-mm-dd
+	PeriodStart string `json:"period_start"`
+
+	// PeriodEnd is the last day of the period being charged. It follows the format yyyy-mm-dd
+	PeriodEnd string `json:"period_end"`
+
+	// DueDate is the date the invoice must be paid by. It follows the format yyyy-mm-dd
+	DueDate string `json:"due_date"`
+
+	// Status is the status of the invoice. It might be one of:
+	//  0 Unpaid - InvoiceStatusUnpaid
+	//  1 Paid   - InvoiceStatusPaid
+	//  2 Voided - InvoiceStatusVoided
+	Status int `json:"status"`
+
+	// PaymentLink is the URL where the customer can pay the invoice.
+	PaymentLink string `json:"paymentLink"`
+}
+
+// GetInvoice fetches an Invoice based on the provided Flow identifier.
+func (c Client) GetInvoice(invoiceID int) (*Invoice, error) {
+	return c.GetInvoiceContext(context.Background(), invoiceID)
+}
+
+// GetInvoiceContext is like GetInvoice, but the request is bound to ctx.
+func (c Client) GetInvoiceContext(ctx context.Context, invoiceID int) (*Invoice, error) {
+	url, err := c.buildGET("/invoice/get", map[string]interface{}{
+		"invoiceId": invoiceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to transact with the server")
+	}
+
+	var invoice Invoice
+	err = jsoniter.Unmarshal(data, &invoice)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse response")
+	}
+
+	return &invoice, err
+}
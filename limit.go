@@ -0,0 +1,161 @@
+package flow
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter bounds the requests made to Flow. It combines a token bucket, refilled at a steady rate up to a burst, and a
+// cap on the number of requests in flight.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	slots chan struct{}
+}
+
+// NewLimiter creates a *Limiter that allows rate requests per second, with bursts of up to burst requests, and no more
+// than maxInFlight concurrent requests. A rate or maxInFlight lower or equal to zero disables that limit.
+func NewLimiter(rate float64, burst int, maxInFlight int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+
+	return l
+}
+
+// Wait blocks until a request can be made, or until ctx is done, in which case ctx's error is returned. The returned
+// function must be called once the request finishes.
+func (l *Limiter) Wait(ctx context.Context) (release func(), err error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release = func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	err = l.take(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// clone returns a full *Limiter with the same settings, or nil if l is nil.
+func (l *Limiter) clone() *Limiter {
+	if l == nil {
+		return nil
+	}
+
+	return NewLimiter(l.rate, int(l.burst), cap(l.slots))
+}
+
+// take takes a token from the bucket, waiting for it to be refilled if it's empty.
+func (l *Limiter) take(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// The token is reserved right away, leaving the bucket in debt while waiting for it.
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// RateLimits are the limiters applied to the requests of a Client, with separate budgets per group of endpoints.
+type RateLimits struct {
+	// Default limits the requests to the endpoints whose group isn't in Groups. If nil, they aren't limited.
+	Default *Limiter
+
+	// Groups maps a group of endpoints to its Limiter. The group of an endpoint is its first path segment, like
+	// "payment" for /payment/getStatus or "refund" for /refund/create.
+	Groups map[string]*Limiter
+}
+
+// clone returns limits with the same settings and budgets of their own, or nil if rl is nil.
+func (rl *RateLimits) clone() *RateLimits {
+	if rl == nil {
+		return nil
+	}
+
+	clone := &RateLimits{
+		Default: rl.Default.clone(),
+		Groups:  make(map[string]*Limiter, len(rl.Groups)),
+	}
+	for group, limiter := range rl.Groups {
+		clone.Groups[group] = limiter.clone()
+	}
+
+	return clone
+}
+
+// wait blocks until a request to the endpoint can be made. The returned function must be called once the request
+// finishes.
+func (rl *RateLimits) wait(ctx context.Context, endpoint string) (release func(), err error) {
+	if rl == nil {
+		return func() {}, nil
+	}
+
+	group := strings.SplitN(strings.TrimPrefix(endpoint, "/"), "/", 2)[0]
+	limiter, set := rl.Groups[group]
+	if !set {
+		limiter = rl.Default
+	}
+
+	if limiter == nil {
+		return func() {}, nil
+	}
+
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestLimiterCancel checks that a canceled wait returns its token and its slot, so it doesn't delay the next
+// requests.
+func TestLimiterCancel(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        float64
+		maxInFlight int
+	}{
+		{name: "rate", rate: 1},
+		{name: "in flight", maxInFlight: 1},
+		{name: "rate and in flight", rate: 1, maxInFlight: 1},
+	}
+
+	for _, test := range tests {
+		l := NewLimiter(test.rate, 1, test.maxInFlight)
+
+		release, err := l.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Wait() failed: %v", test.name, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err = l.Wait(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("%s: Wait() over the limit = %v, want %v", test.name, err, context.DeadlineExceeded)
+		}
+
+		release()
+		if test.rate > 0 {
+			l.mu.Lock()
+			tokens := l.tokens
+			l.mu.Unlock()
+
+			if tokens < -0.5 {
+				t.Errorf("%s: %.2f tokens after the wait was canceled, want the token returned", test.name, tokens)
+			}
+		}
+		if len(l.slots) != 0 {
+			t.Errorf("%s: %d slots taken after every request finished, want 0", test.name, len(l.slots))
+		}
+	}
+}
+
+// TestLimiterRate checks that the requests over the burst wait for the bucket to be refilled.
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter(20, 2, 0)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("Wait() failed: %v", err)
+		}
+		release()
+	}
+
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Fatalf("3 requests with a burst of 2 took %v, want at least 45ms", elapsed)
+	}
+}
+
+// TestRateLimitsGroups checks that each endpoint waits on the limiter of its group, or on the default one.
+func TestRateLimitsGroups(t *testing.T) {
+	payment := NewLimiter(0, 1, 1)
+	limits := &RateLimits{
+		Default: NewLimiter(0, 1, 1),
+		Groups: map[string]*Limiter{
+			"payment": payment,
+			"refund":  nil,
+		},
+	}
+
+	// The payment group is kept busy, so only its endpoints are blocked.
+	_, err := payment.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait() failed: %v", err)
+	}
+
+	tests := []struct {
+		endpoint    string
+		wantBlocked bool
+	}{
+		{endpoint: "/payment/getStatus", wantBlocked: true},
+		{endpoint: "payment/create", wantBlocked: true},
+		{endpoint: "/refund/create", wantBlocked: false},
+		{endpoint: "/customer/get", wantBlocked: false},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		release, err := limits.wait(ctx, test.endpoint)
+		cancel()
+
+		if (err != nil) != test.wantBlocked {
+			t.Errorf("wait(%q) = %v, want blocked %v", test.endpoint, err, test.wantBlocked)
+		}
+		if err == nil {
+			release()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package flow
+
+import (
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Logger receives the logs of a Client. Each method takes a message followed by alternating keys and values, the same
+// way log/slog does, so a *slog.Logger can be used as a Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// redacted replaces the sensitive values in the logs.
+const redacted = "REDACTED"
+
+// sensitiveParams are the request parameters that are never logged.
+var sensitiveParams = []string{"apiKey", "s", "email", "receiverEmail", "payer"}
+
+// emailPattern matches the email addresses in a response body.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// requestInfo describes a request made to the Flow API.
+type requestInfo struct {
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Endpoint is the path of the request, relative to the client's base URL.
+	Endpoint string
+
+	// URL is the complete URL of the request, including the query parameters.
+	URL *url.URL
+
+	// Body is the form encoded body of the request.
+	Body string
+
+	// StatusCode is the HTTP status of the response, or 0 if there was no response.
+	StatusCode int
+
+	// Response is the body of the response.
+	Response []byte
+
+	// Duration is the time the request took.
+	Duration time.Duration
+
+	// Err is the error the request failed with, if any.
+	Err error
+}
+
+// flowCode returns the error code sent by Flow, or 0 if the request didn't fail with an error response.
+func (ri requestInfo) flowCode() int {
+	rqError, ok := errors.Cause(ri.Err).(*requestError)
+	if !ok {
+		return 0
+	}
+
+	return rqError.Code
+}
+
+// logRequest reports a request to the client's Logger. The bodies are only logged at the debug level if LogBodies is
+// set, and always without the credentials, the signature and the payer's emails.
+func (c Client) logRequest(info requestInfo) {
+	if c.Logger == nil {
+		return
+	}
+
+	args := []interface{}{
+		"endpoint", info.Endpoint,
+		"method", info.Method,
+		"duration", info.Duration,
+		"status", info.StatusCode,
+		"request_size", len(info.Body),
+		"response_size", len(info.Response),
+	}
+
+	if info.Err != nil {
+		args = append(args, "flow_code", info.flowCode(), "error", info.Err.Error())
+		c.Logger.Error("flow request failed", args...)
+	} else {
+		c.Logger.Info("flow request", args...)
+	}
+
+	if c.LogBodies {
+		c.Logger.Debug("flow request body",
+			"endpoint", info.Endpoint,
+			"query", redactQuery(info.URL.RawQuery),
+			"body", redactQuery(info.Body),
+			"response", emailPattern.ReplaceAllString(string(info.Response), redacted),
+		)
+	}
+}
+
+// redactQuery replaces the sensitive parameters and the emails of a form encoded string.
+func redactQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return redacted
+	}
+
+	for key := range values {
+		for i, value := range values[key] {
+			values[key][i] = emailPattern.ReplaceAllString(value, redacted)
+		}
+	}
+
+	for _, key := range sensitiveParams {
+		if _, set := values[key]; set {
+			values.Set(key, redacted)
+		}
+	}
+
+	return values.Encode()
+}
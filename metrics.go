@@ -0,0 +1,129 @@
+package flow
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Metrics receives the measurements of a Client, to be exported to a monitoring system.
+//
+// The outcome of a request is one of "success", "api_error" (Flow rejected the request), "server_error" (Flow failed
+// to process the request) or "network_error" (Flow couldn't be reached).
+//
+// The outcome of a callback is the status of the notified order or refund, like "payed", "rejected" or "refunded",
+// or one of "invalid_token" (the token was missing or rejected by Flow), "in_flight" (the same notification was being
+// delivered by another request) or "internal_error" (the token couldn't be verified or the callback failed).
+type Metrics interface {
+	// ObserveRequest records a request made to a Flow API endpoint.
+	ObserveRequest(endpoint, outcome string, duration time.Duration)
+
+	// ObserveCallback records a notification received from Flow. The kind is one of "order", "refund", "register" or
+	// "invoice".
+	ObserveCallback(kind, outcome string)
+}
+
+const (
+	outcomeSuccess       = "success"
+	outcomeAPIError      = "api_error"
+	outcomeServerError   = "server_error"
+	outcomeNetworkError  = "network_error"
+	outcomeInvalidToken  = "invalid_token"
+	outcomeInFlight      = "in_flight"
+	outcomeInternalError = "internal_error"
+)
+
+// observeRequest reports a request to the client's Metrics.
+func (c Client) observeRequest(info requestInfo) {
+	if c.Metrics == nil {
+		return
+	}
+
+	outcome := outcomeSuccess
+	if info.Err != nil {
+		switch {
+		case info.StatusCode == 0:
+			outcome = outcomeNetworkError
+		case info.StatusCode >= http.StatusInternalServerError:
+			outcome = outcomeServerError
+		default:
+			outcome = outcomeAPIError
+		}
+	}
+
+	c.Metrics.ObserveRequest(info.Endpoint, outcome, info.Duration)
+}
+
+// observeCallback reports a notification to the client's Metrics.
+func (c Client) observeCallback(kind, outcome string) {
+	if c.Metrics == nil {
+		return
+	}
+
+	c.Metrics.ObserveCallback(kind, outcome)
+}
+
+// resolveOutcome is the callback outcome of a token that couldn't be resolved.
+func resolveOutcome(err error) string {
+	rqError, ok := errors.Cause(err).(*requestError)
+	if ok && rqError.StatusCode < http.StatusInternalServerError {
+		return outcomeInvalidToken
+	}
+
+	return outcomeInternalError
+}
+
+// callbackOutcome is the callback outcome of a resolved notification answered with the given status.
+func callbackOutcome(status int, resolved string) string {
+	if status >= http.StatusInternalServerError {
+		return outcomeInternalError
+	}
+	if status == http.StatusConflict {
+		return outcomeInFlight
+	}
+
+	return resolved
+}
+
+// orderStatusName is the name of an order status used in the metrics.
+func orderStatusName(status int) string {
+	switch status {
+	case OrderStatusAwaitingPayment:
+		return "pending"
+	case OrderStatusPayed:
+		return "payed"
+	case OrderStatusRejected:
+		return "rejected"
+	case OrderStatusCanceled:
+		return "canceled"
+	}
+
+	return "unknown"
+}
+
+// registerStatusName is the name of a card registration status used in the metrics.
+func registerStatusName(status string) string {
+	switch status {
+	case RegisterStatusPending:
+		return "pending"
+	case RegisterStatusRegistered:
+		return "registered"
+	}
+
+	return "unknown"
+}
+
+// invoiceStatusName is the name of an invoice status used in the metrics.
+func invoiceStatusName(status int) string {
+	switch status {
+	case InvoiceStatusUnpaid:
+		return "unpaid"
+	case InvoiceStatusPaid:
+		return "paid"
+	case InvoiceStatusVoided:
+		return "voided"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,196 @@
+package flow
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/json-iterator/go"
+)
+
+// Param is a parameter of a request made to Flow.
+type Param struct {
+	// Key is the name of the parameter.
+	Key string
+
+	// Value is the canonical encoding of the parameter's value.
+	Value string
+}
+
+// Params is the list of parameters of a request, sorted by key. The same list is used to compute the signature and to
+// encode the body or query of the request, so both always match.
+type Params []Param
+
+// EncodeParams converts the data of a request into its canonical Params. The data might be a map with string keys or
+// a struct, whose fields are named by their "structs" tag. Fields tagged "-" are skipped, and fields tagged with the
+// omitempty option are skipped when they hold their zero value. Nil values are always skipped.
+//
+// The values are encoded as follows:
+//   - Strings are kept as they are.
+//   - Integers are written in base 10.
+//   - Floats are written in decimal notation with the minimum number of digits, never in scientific notation.
+//   - Booleans are written as "true" or "false".
+//   - Structs, maps and slices are written as JSON, which is how Flow receives nested values like "optional".
+func EncodeParams(data interface{}) Params {
+	var params Params
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			value, ok := encodeValue(v.MapIndex(key))
+			if ok {
+				params = append(params, Param{Key: fmt.Sprint(key.Interface()), Value: value})
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name, omitEmpty := parseTag(field)
+			if name == "-" {
+				continue
+			}
+
+			if omitEmpty && v.Field(i).IsZero() {
+				continue
+			}
+
+			value, ok := encodeValue(v.Field(i))
+			if ok {
+				params = append(params, Param{Key: name, Value: value})
+			}
+		}
+	}
+
+	params.sort()
+	return params
+}
+
+// Get returns the value of the parameter with the given key, or an empty string if there is none.
+func (p Params) Get(key string) string {
+	for _, param := range p {
+		if param.Key == key {
+			return param.Value
+		}
+	}
+
+	return ""
+}
+
+// Set returns the params with the parameter set to value, replacing its previous value if any.
+func (p Params) Set(key, value string) Params {
+	for i := range p {
+		if p[i].Key == key {
+			p[i].Value = value
+			return p
+		}
+	}
+
+	p = append(p, Param{Key: key, Value: value})
+	p.sort()
+	return p
+}
+
+// Encode form encodes the params in their order.
+func (p Params) Encode() string {
+	var sb strings.Builder
+	for i, param := range p {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(param.Key))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(param.Value))
+	}
+
+	return sb.String()
+}
+
+// signingString is the string signed by Flow's signing scheme: each key followed by its value, in order.
+func (p Params) signingString() string {
+	var sb strings.Builder
+	for _, param := range p {
+		sb.WriteString(param.Key)
+		sb.WriteString(param.Value)
+	}
+
+	return sb.String()
+}
+
+// sort sorts the params by key.
+func (p Params) sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].Key < p[j].Key
+	})
+}
+
+// parseTag returns the parameter name of a struct field and whether it has the omitempty option.
+func parseTag(field reflect.StructField) (name string, omitEmpty bool) {
+	tag := field.Tag.Get("structs")
+	parts := strings.Split(tag, ",")
+
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty
+}
+
+// encodeValue returns the canonical encoding of a value. It returns false if the value is nil.
+func encodeValue(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), true
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+			return "", false
+		}
+
+		data, err := jsoniter.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprintf("%v", v.Interface()), true
+		}
+		return string(data), true
+	}
+
+	return fmt.Sprintf("%v", v.Interface()), true
+}
@@ -0,0 +1,167 @@
+package flow
+
+import (
+	"net/url"
+	"sort"
+	"testing"
+)
+
+// TestSignDocumentedExample checks the signing example of Flow's documentation.
+func TestSignDocumentedExample(t *testing.T) {
+	params := EncodeParams(map[string]interface{}{
+		"apiKey":   "XXXX-XXXX-XXXX",
+		"currency": "CLP",
+		"amount":   5000,
+	})
+
+	const wantString = "amount5000apiKeyXXXX-XXXX-XXXXcurrencyCLP"
+	if got := params.signingString(); got != wantString {
+		t.Fatalf("signingString() = %q, want %q", got, wantString)
+	}
+
+	const wantSignature = "65174b97f344a6bcbf6f2511eb957e4ab5666040148a3987b0c41b174d36c7ed"
+	if got := signParams(params, "YYYY-YYYY-YYYY"); got != wantSignature {
+		t.Fatalf("signParams() = %q, want %q", got, wantSignature)
+	}
+
+	c := NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY")
+	signature, err := c.Sign(map[string]interface{}{
+		"apiKey":   "XXXX-XXXX-XXXX",
+		"currency": "CLP",
+		"amount":   5000,
+		"s":        "ignored",
+	})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if signature != wantSignature {
+		t.Fatalf("Sign() = %q, want %q", signature, wantSignature)
+	}
+}
+
+// TestEncodeParams checks the canonical encoding of each kind of value.
+func TestEncodeParams(t *testing.T) {
+	type nested struct {
+		RUT string `json:"RUT"`
+	}
+
+	type request struct {
+		Name     string   `structs:"name"`
+		Amount   uint64   `structs:"amount"`
+		Rate     float64  `structs:"rate"`
+		Small    float32  `structs:"small"`
+		Big      float64  `structs:"big"`
+		Enabled  bool     `structs:"enabled"`
+		Disabled bool     `structs:"disabled"`
+		Optional nested   `structs:"optional"`
+		Pointer  *string  `structs:"pointer"`
+		Tags     []string `structs:"tags"`
+		Currency string   `structs:"currency,omitempty"`
+		Timeout  uint64   `structs:"timeout,omitempty"`
+		Skipped  string   `structs:"-"`
+		Untagged string
+	}
+
+	params := EncodeParams(request{
+		Name:     "Test Order",
+		Amount:   1000,
+		Rate:     0.1,
+		Small:    1.5,
+		Big:      1e21,
+		Enabled:  true,
+		Optional: nested{RUT: "1-9"},
+		Skipped:  "skipped",
+		Untagged: "untagged",
+	})
+
+	want := Params{
+		{Key: "Untagged", Value: "untagged"},
+		{Key: "amount", Value: "1000"},
+		{Key: "big", Value: "1000000000000000000000"},
+		{Key: "disabled", Value: "false"},
+		{Key: "enabled", Value: "true"},
+		{Key: "name", Value: "Test Order"},
+		{Key: "optional", Value: `{"RUT":"1-9"}`},
+		{Key: "rate", Value: "0.1"},
+		{Key: "small", Value: "1.5"},
+	}
+
+	if len(params) != len(want) {
+		t.Fatalf("EncodeParams() = %v, want %v", params, want)
+	}
+	for i := range want {
+		if params[i] != want[i] {
+			t.Errorf("param %d = %v, want %v", i, params[i], want[i])
+		}
+	}
+}
+
+// TestEncodeParamsMap checks that nil values of a map are skipped.
+func TestEncodeParamsMap(t *testing.T) {
+	params := EncodeParams(map[string]interface{}{
+		"token":  "abc",
+		"nil":    nil,
+		"amount": 10.0,
+	})
+
+	want := "amount=10&token=abc"
+	if got := params.Encode(); got != want {
+		t.Fatalf("Encode() = %q, want %q", got, want)
+	}
+}
+
+// TestRequestsMatchSignature checks that the parameters sent in the body and query of the requests are the ones
+// signed.
+func TestRequestsMatchSignature(t *testing.T) {
+	c := NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY")
+	data := map[string]interface{}{
+		"commerceOrder": "order 1&2",
+		"subject":       "Café + té",
+		"amount":        5000,
+		"rate":          0.25,
+		"optional":      map[string]string{"RUT": "1-9"},
+	}
+
+	_, body, err := c.buildPOST("/payment/create", data)
+	if err != nil {
+		t.Fatalf("buildPOST() failed: %v", err)
+	}
+
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		t.Fatalf("invalid body %q: %v", body, err)
+	}
+	checkSigned(t, values)
+
+	rqURL, err := c.buildGET("/payment/getStatus", data)
+	if err != nil {
+		t.Fatalf("buildGET() failed: %v", err)
+	}
+	checkSigned(t, rqURL.Query())
+}
+
+// checkSigned checks that the "s" value is the signature of the other values, as Flow computes it.
+func checkSigned(t *testing.T, values url.Values) {
+	t.Helper()
+
+	var keys []string
+	for key := range values {
+		if key != "s" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var message string
+	for _, key := range keys {
+		message += key + values.Get(key)
+	}
+
+	if want := hmac256Sign(message, "YYYY-YYYY-YYYY"); values.Get("s") != want {
+		t.Fatalf("signature %q doesn't match the sent values %v, want %q", values.Get("s"), values, want)
+	}
+
+	if !NewClient("XXXX-XXXX-XXXX", "YYYY-YYYY-YYYY").VerifyValues(values) {
+		t.Fatalf("VerifyValues() rejected the sent values %v", values)
+	}
+}
@@ -1,8 +1,9 @@
 package flow
 
 import (
+	"context"
 	"fmt"
-	"github.com/fatih/structs"
+	"time"
 	"github.com/json-iterator/go"
 	"github.com/pkg/errors"
 )
@@ -29,7 +30,7 @@ type OrderRequest struct {
 	PaymentMethod   int                    `structs:"paymentMethod,omitempty"`
 
 	// ConfirmationURL is the URL to which Flow will send the order details after the payment is made. This URL must
-	// implement the confirmation logic. See GinOrderConfirmationCallback and HTTPOrderConfirmationCallback.
+	// implement the confirmation logic. See HTTPOrderCallbacks and ConfirmOrder.
 	ConfirmationURL string                 `structs:"urlConfirmation,omitempty"`
 
 	// ReturnURL is the URL to which the user will be sent after the payment is made and confirmed. Usually on this
@@ -75,11 +76,19 @@ func (or OrderRequest) isValid() bool {
 
 // GetOrder fetches the an Order based on the provided order token.
 func (c Client) GetOrder(token string) (*Order, error) {
-	url := c.buildGET("/payment/getStatus", map[string]interface{}{
+	return c.GetOrderContext(context.Background(), token)
+}
+
+// GetOrderContext is like GetOrder, but the request is bound to ctx.
+func (c Client) GetOrderContext(ctx context.Context, token string) (*Order, error) {
+	url, err := c.buildGET("/payment/getStatus", map[string]interface{}{
 		"token": token,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.get(url)
+	data, err := c.get(ctx, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -95,11 +104,19 @@ func (c Client) GetOrder(token string) (*Order, error) {
 
 // GetOrderByCommerceID fetches the an Order based on the provided commerce identifier.
 func (c Client) GetOrderByCommerceID(commerceID string) (*Order, error) {
-	url := c.buildGET("/payment/getStatusByCommerceId", map[string]interface{}{
+	return c.GetOrderByCommerceIDContext(context.Background(), commerceID)
+}
+
+// GetOrderByCommerceIDContext is like GetOrderByCommerceID, but the request is bound to ctx.
+func (c Client) GetOrderByCommerceIDContext(ctx context.Context, commerceID string) (*Order, error) {
+	url, err := c.buildGET("/payment/getStatusByCommerceId", map[string]interface{}{
 		"commerceId": commerceID,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.get(url)
+	data, err := c.get(ctx, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -115,11 +132,19 @@ func (c Client) GetOrderByCommerceID(commerceID string) (*Order, error) {
 
 // GetOrderByFlowID fetches the an Order based on the provided Flow identifier.
 func (c Client) GetOrderByFlowID(flowOrderID int) (*Order, error) {
-	url := c.buildGET("/payment/getStatusByFlowOrder", map[string]interface{}{
+	return c.GetOrderByFlowIDContext(context.Background(), flowOrderID)
+}
+
+// GetOrderByFlowIDContext is like GetOrderByFlowID, but the request is bound to ctx.
+func (c Client) GetOrderByFlowIDContext(ctx context.Context, flowOrderID int) (*Order, error) {
+	url, err := c.buildGET("/payment/getStatusByFlowOrder", map[string]interface{}{
 		"flowOrder": flowOrderID,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.get(url)
+	data, err := c.get(ctx, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -134,14 +159,22 @@ func (c Client) GetOrderByFlowID(flowOrderID int) (*Order, error) {
 }
 
 // CreateOrder creates a new order and returns its ID and token.
-func (c Client) CreateOrder(or OrderRequest) ( *OrderResponse, error) {
+func (c Client) CreateOrder(or OrderRequest) (*OrderResponse, error) {
+	return c.CreateOrderContext(context.Background(), or)
+}
+
+// CreateOrderContext is like CreateOrder, but the request is bound to ctx.
+func (c Client) CreateOrderContext(ctx context.Context, or OrderRequest) (*OrderResponse, error) {
 	if !or.isValid() {
 		return nil, errors.New("invalid order request: unfilled required values")
 	}
 
-	url, body := c.buildPOST("/payment/create", structs.Map(or))
+	url, body, err := c.buildPOST("/payment/create", or)
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.post(url, body)
+	data, err := c.post(ctx, url, body)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -157,13 +190,21 @@ func (c Client) CreateOrder(or OrderRequest) ( *OrderResponse, error) {
 
 // CreateEmailOrder creates an Order to be sent by email and returns its ID and token.
 func (c Client) CreateEmailOrder(or OrderRequest) (orderID int, token string, err error) {
+	return c.CreateEmailOrderContext(context.Background(), or)
+}
+
+// CreateEmailOrderContext is like CreateEmailOrder, but the request is bound to ctx.
+func (c Client) CreateEmailOrderContext(ctx context.Context, or OrderRequest) (orderID int, token string, err error) {
 	if !or.isValid() {
 		return -1, "", errors.New("invalid order request: unfilled required values")
 	}
 
-	url, body := c.buildPOST("/payment/createEmail", structs.Map(or))
+	url, body, err := c.buildPOST("/payment/createEmail", or)
+	if err != nil {
+		return -1, "", err
+	}
 
-	data, err := c.post(url, body)
+	data, err := c.post(ctx, url, body)
 	if err != nil {
 		return -1, "", errors.Wrap(err, "unable to transact with the server")
 	}
@@ -178,4 +219,47 @@ func (c Client) CreateEmailOrder(or OrderRequest) (orderID int, token string, er
 	}
 
 	return result.FlowID, result.Token, err
-}
\ No newline at end of file
+}
+
+// PaymentList is a page of the payments received on a date.
+type PaymentList struct {
+	// Total is the number of payments received on the date.
+	Total int `json:"total"`
+
+	// HasMore is 1 if there are payments after this page, and 0 otherwise.
+	HasMore int `json:"hasMore"`
+
+	// Data are the orders paid, in this page.
+	Data []Order `json:"data"`
+}
+
+// GetPayments fetches a page of the payments received on a date, starting from the payment at index start. Flow
+// returns at most 100 payments per page.
+func (c Client) GetPayments(date time.Time, start, limit int) (*PaymentList, error) {
+	return c.GetPaymentsContext(context.Background(), date, start, limit)
+}
+
+// GetPaymentsContext is like GetPayments, but the request is bound to ctx.
+func (c Client) GetPaymentsContext(ctx context.Context, date time.Time, start, limit int) (*PaymentList, error) {
+	url, err := c.buildGET("/payment/getPayments", map[string]interface{}{
+		"date":  date.Format("2006-01-02"),
+		"start": start,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to transact with the server")
+	}
+
+	var list PaymentList
+	err = jsoniter.Unmarshal(data, &list)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse response")
+	}
+
+	return &list, err
+}
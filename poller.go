@@ -0,0 +1,273 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PendingOrder is an order waiting for a final status, checked by a Poller.
+type PendingOrder struct {
+	// Token is the token of the order. If empty, the order is looked up by its CommerceOrder.
+	Token string `json:"token,omitempty"`
+
+	// CommerceOrder is the commerce order of the order.
+	CommerceOrder string `json:"commerceOrder,omitempty"`
+
+	// Created is the time the order was created.
+	Created time.Time `json:"created"`
+
+	// TimeoutSeconds is the number of seconds the order stays payable after it's created, as set in the OrderRequest.
+	// Once elapsed, the order is expired. If zero, the order is checked until it reaches a final status.
+	TimeoutSeconds uint64 `json:"timeoutSeconds,omitempty"`
+
+	// Attempts is the number of times the order was checked.
+	Attempts int `json:"attempts"`
+
+	// NextCheck is the time the order is due to be checked again. A zero value means it's due now.
+	NextCheck time.Time `json:"nextCheck"`
+}
+
+// expired reports whether the order's TimeoutSeconds elapsed at now.
+func (po PendingOrder) expired(now time.Time) bool {
+	if po.TimeoutSeconds == 0 {
+		return false
+	}
+
+	return now.After(po.Created.Add(time.Duration(po.TimeoutSeconds) * time.Second))
+}
+
+// key identifies the order in a MemoryPendingOrderStore.
+func (po PendingOrder) key() string {
+	if po.Token != "" {
+		return "token-" + po.Token
+	}
+
+	return "commerce-" + po.CommerceOrder
+}
+
+// PendingOrderStore holds the orders checked by a Poller. Implementations must be safe for concurrent use.
+type PendingOrderStore interface {
+	// Pending returns the orders waiting for a final status.
+	Pending() ([]PendingOrder, error)
+
+	// Update saves the Attempts and NextCheck of an order.
+	Update(order PendingOrder) error
+
+	// Remove forgets an order, once it reached a final status or expired.
+	Remove(order PendingOrder) error
+}
+
+// MemoryPendingOrderStore is a PendingOrderStore that keeps the orders in memory.
+type MemoryPendingOrderStore struct {
+	mu     sync.Mutex
+	orders map[string]PendingOrder
+}
+
+// NewMemoryPendingOrderStore creates an empty *MemoryPendingOrderStore.
+func NewMemoryPendingOrderStore() *MemoryPendingOrderStore {
+	return &MemoryPendingOrderStore{
+		orders: make(map[string]PendingOrder),
+	}
+}
+
+// Add adds an order to be checked.
+func (s *MemoryPendingOrderStore) Add(order PendingOrder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[order.key()] = order
+}
+
+// Pending returns the orders waiting for a final status.
+func (s *MemoryPendingOrderStore) Pending() ([]PendingOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := make([]PendingOrder, 0, len(s.orders))
+	for _, order := range s.orders {
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// Update saves the Attempts and NextCheck of an order.
+func (s *MemoryPendingOrderStore) Update(order PendingOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.key()]; ok {
+		s.orders[order.key()] = order
+	}
+
+	return nil
+}
+
+// Remove forgets an order.
+func (s *MemoryPendingOrderStore) Remove(order PendingOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.orders, order.key())
+	return nil
+}
+
+// PollerOptions configures a Poller.
+type PollerOptions struct {
+	// Interval is how often Run looks for orders due to be checked. It defaults to 30 seconds.
+	Interval time.Duration
+
+	// Backoff returns how long to wait before checking an order again after the given attempt (starting at 1). It
+	// defaults to 30 seconds, doubled on each attempt up to 30 minutes.
+	Backoff func(attempt int) time.Duration
+
+	// OnExpired is optionally set to be called for the orders whose TimeoutSeconds elapsed without a final status. If
+	// it fails, the order is checked again later.
+	OnExpired func(ctx context.Context, order PendingOrder) error
+}
+
+// Poller checks the status of pending orders with Flow, for when the confirmation callbacks are missed. When an
+// order reaches a final status, the function of its callbacks is called as a confirmation handler would, and the
+// order is removed from the store. If the Client has an IdempotencyStore, a status delivered by both a callback and
+// the Poller is processed only once.
+type Poller struct {
+	client    *Client
+	store     PendingOrderStore
+	callbacks OrderCallbacks
+	opts      PollerOptions
+}
+
+// NewPoller creates a *Poller that checks the orders in store with the client, and delivers their final status to
+// callbacks. OnPending is never called by the Poller.
+func NewPoller(c *Client, store PendingOrderStore, callbacks OrderCallbacks, opts PollerOptions) *Poller {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = func(attempt int) time.Duration {
+			if attempt > 6 {
+				return 30 * time.Minute
+			}
+
+			return 30 * time.Second << uint(attempt-1)
+		}
+	}
+
+	callbacks.OnPending = nil
+	return &Poller{
+		client:    c,
+		store:     store,
+		callbacks: callbacks,
+		opts:      opts,
+	}
+}
+
+// Run checks the due orders every Interval until ctx is done, and returns ctx's error.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := p.Poll(ctx)
+		if err != nil {
+			p.logError("unable to poll pending orders", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll checks once the orders due to be checked. Orders that couldn't be checked are rescheduled following Backoff.
+// It returns the first error reading or updating the store.
+func (p *Poller) Poll(ctx context.Context) error {
+	orders, err := p.store.Pending()
+	if err != nil {
+		return errors.Wrap(err, "unable to load pending orders")
+	}
+
+	var firstErr error
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		now := time.Now()
+		if now.Before(order.NextCheck) {
+			continue
+		}
+
+		err = p.check(ctx, order, now)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// check checks an order, delivers its final status and removes it, or reschedules it.
+func (p *Poller) check(ctx context.Context, pending PendingOrder, now time.Time) error {
+	pending.Attempts++
+
+	order, err := p.lookup(ctx, pending)
+	if err != nil {
+		p.logError("unable to check pending order", err, "commerceOrder", pending.CommerceOrder)
+		return p.reschedule(pending, now)
+	}
+
+	switch order.Status {
+	case OrderStatusPayed, OrderStatusRejected, OrderStatusCanceled:
+		fn := p.callbacks.forStatus(order.Status)
+		if fn != nil && p.client.dispatch(orderKey(order), func() error { return fn(ctx, order) }) != http.StatusOK {
+			return p.reschedule(pending, now)
+		}
+
+		return errors.Wrap(p.store.Remove(pending), "unable to remove pending order")
+	}
+
+	if !pending.expired(now) {
+		return p.reschedule(pending, now)
+	}
+
+	if p.opts.OnExpired != nil {
+		err = p.opts.OnExpired(ctx, pending)
+		if err != nil {
+			p.logError("unable to expire pending order", err, "commerceOrder", pending.CommerceOrder)
+			return p.reschedule(pending, now)
+		}
+	}
+
+	return errors.Wrap(p.store.Remove(pending), "unable to remove pending order")
+}
+
+// lookup fetches a pending order by its token, or by its commerce order if it has no token.
+func (p *Poller) lookup(ctx context.Context, pending PendingOrder) (*Order, error) {
+	if pending.Token != "" {
+		return p.client.GetOrderContext(ctx, pending.Token)
+	}
+
+	return p.client.GetOrderByCommerceIDContext(ctx, pending.CommerceOrder)
+}
+
+// reschedule saves the order to be checked again after the backoff of its attempt.
+func (p *Poller) reschedule(pending PendingOrder, now time.Time) error {
+	pending.NextCheck = now.Add(p.opts.Backoff(pending.Attempts))
+	return errors.Wrap(p.store.Update(pending), "unable to update pending order")
+}
+
+// logError logs an error on the client's Logger, if any.
+func (p *Poller) logError(msg string, err error, args ...interface{}) {
+	if p.client.Logger == nil {
+		return
+	}
+
+	p.client.Logger.Error(msg, append([]interface{}{"error", err}, args...)...)
+}
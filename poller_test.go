@@ -0,0 +1,170 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestPollerPoll checks what a poll does with a pending order depending on its status with Flow.
+func TestPollerPoll(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	flow.setOrder("paid", &Order{FlowOrder: 1, CommerceOrder: "paid-order", Status: OrderStatusPayed})
+	flow.setOrder("rejected", &Order{FlowOrder: 2, CommerceOrder: "rejected-order", Status: OrderStatusRejected})
+	flow.setOrder("pending", &Order{FlowOrder: 3, CommerceOrder: "pending-order", Status: OrderStatusAwaitingPayment})
+
+	now := time.Now()
+	tests := []struct {
+		name         string
+		order        PendingOrder
+		failCallback bool
+		failExpired  bool
+		wantCalled   string
+		wantRemoved  bool
+		wantAttempts int
+	}{
+		{
+			name:        "paid",
+			order:       PendingOrder{Token: "paid", Created: now},
+			wantCalled:  "OnPaid",
+			wantRemoved: true,
+		},
+		{
+			name:        "rejected by commerce order",
+			order:       PendingOrder{CommerceOrder: "rejected-order", Created: now},
+			wantCalled:  "OnRejected",
+			wantRemoved: true,
+		},
+		{
+			name:         "failed callback",
+			order:        PendingOrder{Token: "paid", Created: now},
+			failCallback: true,
+			wantCalled:   "OnPaid",
+			wantAttempts: 1,
+		},
+		{
+			name:         "pending",
+			order:        PendingOrder{Token: "pending", Created: now, TimeoutSeconds: 60},
+			wantAttempts: 1,
+		},
+		{
+			name:        "expired",
+			order:       PendingOrder{Token: "pending", Created: now.Add(-time.Hour), TimeoutSeconds: 60},
+			wantCalled:  "OnExpired",
+			wantRemoved: true,
+		},
+		{
+			name:         "failed expiration",
+			order:        PendingOrder{Token: "pending", Created: now.Add(-time.Hour), TimeoutSeconds: 60},
+			failExpired:  true,
+			wantCalled:   "OnExpired",
+			wantAttempts: 1,
+		},
+		{
+			name:         "unknown order",
+			order:        PendingOrder{Token: "unknown", Created: now},
+			wantAttempts: 1,
+		},
+		{
+			name:  "not due",
+			order: PendingOrder{Token: "paid", Created: now, NextCheck: now.Add(time.Hour)},
+		},
+	}
+
+	for _, test := range tests {
+		var called string
+		record := func(name string, fail bool) OrderHandlerFunc {
+			return func(context.Context, *Order) error {
+				called = name
+				if fail {
+					return errors.New("callback failed")
+				}
+
+				return nil
+			}
+		}
+
+		store := NewMemoryPendingOrderStore()
+		store.Add(test.order)
+
+		poller := NewPoller(flow.client(), store, OrderCallbacks{
+			OnPaid:     record("OnPaid", test.failCallback),
+			OnRejected: record("OnRejected", test.failCallback),
+			OnPending:  record("OnPending", test.failCallback),
+		}, PollerOptions{
+			Backoff: func(int) time.Duration { return time.Minute },
+			OnExpired: func(context.Context, PendingOrder) error {
+				called = "OnExpired"
+				if test.failExpired {
+					return errors.New("expiration failed")
+				}
+
+				return nil
+			},
+		})
+
+		err := poller.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Poll() failed: %v", test.name, err)
+		}
+
+		if called != test.wantCalled {
+			t.Errorf("%s: called %q, want %q", test.name, called, test.wantCalled)
+		}
+
+		pending, _ := store.Pending()
+		if removed := len(pending) == 0; removed != test.wantRemoved {
+			t.Fatalf("%s: removed = %v, want %v", test.name, removed, test.wantRemoved)
+		}
+		if test.wantRemoved {
+			continue
+		}
+
+		if pending[0].Attempts != test.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", test.name, pending[0].Attempts, test.wantAttempts)
+		}
+		if test.wantAttempts > 0 && pending[0].NextCheck.Before(now.Add(time.Minute)) {
+			t.Errorf("%s: next check at %v, want after the backoff", test.name, pending[0].NextCheck)
+		}
+	}
+}
+
+// TestPollerDeduplicates checks that a status already delivered by a callback isn't delivered again by the poller.
+func TestPollerDeduplicates(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	order := &Order{FlowOrder: 1, Status: OrderStatusPayed}
+	flow.setOrder("paid", order)
+
+	c := flow.client()
+	c.IdempotencyStore = NewMemoryIdempotencyStore()
+	_, _ = c.IdempotencyStore.Claim(orderKey(order), time.Hour)
+	_ = c.IdempotencyStore.Complete(orderKey(order))
+
+	store := NewMemoryPendingOrderStore()
+	store.Add(PendingOrder{Token: "paid", Created: time.Now()})
+
+	calls := 0
+	poller := NewPoller(c, store, OrderCallbacks{
+		OnPaid: func(context.Context, *Order) error {
+			calls++
+			return nil
+		},
+	}, PollerOptions{})
+
+	err := poller.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Poll() failed: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("OnPaid() called %d times, want 0", calls)
+	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Fatalf("Pending() = %v, want the delivered order removed", pending)
+	}
+}
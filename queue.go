@@ -0,0 +1,275 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQueueClosed is returned when closing a Queue that was already closed.
+var ErrQueueClosed = errors.New("queue closed")
+
+// Job is a unit of work processed by a Queue.
+type Job struct {
+	// Key identifies the job, usually with the idempotency key of the notification being processed.
+	Key string
+
+	// Run does the work. It is retried while it returns an error, up to the queue's MaxAttempts.
+	Run func(ctx context.Context) error
+
+	// Done is optionally set to be called once with the final result of the job.
+	Done func(err error)
+
+	// Context is optionally set to the context the job was created in, like the one of the request it processes. Run
+	// receives its values, like its trace context, but is canceled with the queue instead of with it.
+	Context context.Context
+}
+
+// QueueOptions configures a Queue.
+type QueueOptions struct {
+	// Workers is the number of jobs processed concurrently. It defaults to 1.
+	Workers int
+
+	// Size is the maximum number of jobs waiting to be processed. It defaults to 100.
+	Size int
+
+	// MaxAttempts is the number of times a failing job is run before giving up on it. It defaults to 3.
+	MaxAttempts int
+
+	// Backoff returns how long to wait before the given retry attempt (starting at 1) of a failed job. It defaults to
+	// one second, doubled on each attempt.
+	Backoff func(attempt int) time.Duration
+
+	// DeadLetter is optionally set to receive the jobs that failed on every attempt, along with their last error.
+	DeadLetter func(job Job, err error)
+}
+
+// Queue is a bounded in-process job queue processed by a fixed number of workers. It lets the confirmation handlers
+// acknowledge Flow right after verifying a token, and process the notification in the background.
+type Queue struct {
+	opts QueueOptions
+	jobs chan Job
+
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewQueue creates a *Queue and starts its workers.
+func NewQueue(opts QueueOptions) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Size <= 0 {
+		opts.Size = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = func(attempt int) time.Duration {
+			return time.Second << uint(attempt-1)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		opts:   opts,
+		jobs:   make(chan Job, opts.Size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	q.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// Enqueue adds a job to the queue without blocking. It returns false if the queue is full or closed.
+func (q *Queue) Enqueue(job Job) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting jobs and waits for the queued ones to be processed. If ctx is done first, the pending retries
+// are abandoned and ctx's error is returned.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// work processes jobs until the queue is closed.
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for job := range q.jobs {
+		err := q.run(job)
+		if err != nil && q.opts.DeadLetter != nil {
+			q.opts.DeadLetter(job, err)
+		}
+		if job.Done != nil {
+			job.Done(err)
+		}
+	}
+}
+
+// run runs a job until it succeeds or runs out of attempts.
+func (q *Queue) run(job Job) (err error) {
+	for attempt := 1; attempt <= q.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(q.opts.Backoff(attempt - 1)):
+			case <-q.ctx.Done():
+				return err
+			}
+		}
+
+		err = runJob(q.ctx, job)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// runJob runs a job once, turning a panic into an error.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	if job.Context != nil {
+		ctx = jobContext{Context: ctx, values: job.Context}
+	}
+
+	return job.Run(ctx)
+}
+
+// jobContext is the context a job runs in. It's canceled with the queue, and carries the values of the job's Context.
+type jobContext struct {
+	context.Context
+	values context.Context
+}
+
+// Value returns the value of the job's Context for key.
+func (jc jobContext) Value(key interface{}) interface{} {
+	return jc.values.Value(key)
+}
+
+// ConfirmOrderAsync is like ConfirmOrder, but once the token is verified the callback is added to the queue and Flow
+// is acknowledged without waiting for it. If the queue is full, it answers with 503 Service Unavailable so Flow retries
+// later. As Flow isn't told about failures of the callback, set the queue's DeadLetter to recover them.
+func (c *Client) ConfirmOrderAsync(ctx context.Context, token string, q *Queue, callbacks OrderCallbacks) int {
+	return c.confirmOrder(ctx, token, callbacks, http.StatusOK, q)
+}
+
+// ConfirmRefundAsync is like ConfirmRefund, but once the token is verified the callback is added to the queue and Flow
+// is acknowledged without waiting for it. If the queue is full, it answers with 503 Service Unavailable so Flow retries
+// later. As Flow isn't told about failures of the callback, set the queue's DeadLetter to recover them.
+func (c *Client) ConfirmRefundAsync(ctx context.Context, token string, q *Queue, callbacks RefundCallbacks) int {
+	return c.confirmRefund(ctx, token, callbacks, http.StatusOK, q)
+}
+
+// HTTPAsyncOrderCallbacks returns a http.HandlerFunc that processes the Flow callback request with
+// ConfirmOrderAsync.
+func (c *Client) HTTPAsyncOrderCallbacks(q *Queue, callbacks OrderCallbacks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(c.ConfirmOrderAsync(r.Context(), callbackToken(r), q, callbacks))
+	}
+}
+
+// HTTPAsyncRefundCallbacks returns a http.HandlerFunc that processes the Flow callback request with
+// ConfirmRefundAsync.
+func (c *Client) HTTPAsyncRefundCallbacks(q *Queue, callbacks RefundCallbacks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(c.ConfirmRefundAsync(r.Context(), callbackToken(r), q, callbacks))
+	}
+}
+
+// enqueue adds the delivery of a notification identified by key to the queue, and returns the status to answer with.
+// The key stays claimed while the job is queued, so duplicates received meanwhile are answered with 409 Conflict. Flow
+// is acknowledged once the job is queued and won't deliver the notification again: if the job fails on every attempt
+// its key is released, but the only way to recover the notification is the queue's DeadLetter. The job runs with the
+// values of ctx, so its span is a child of the callback's span.
+func (c *Client) enqueue(ctx context.Context, q *Queue, key string, run func(ctx context.Context) error) int {
+	state, err := c.claim(key)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+
+	switch state {
+	case ClaimInFlight:
+		return http.StatusConflict
+	case ClaimDelivered:
+		return http.StatusOK
+	}
+
+	queued := q.Enqueue(Job{
+		Key: key,
+		Run: func(ctx context.Context) error {
+			ctx, span := c.startSpan(ctx, "flow queued callback")
+			defer span.End()
+
+			err := run(ctx)
+			if err != nil {
+				span.SetError(err)
+			}
+
+			return err
+		},
+		Done: func(err error) {
+			_ = c.finish(key, err)
+		},
+		Context: ctx,
+	})
+	if !queued {
+		_ = c.finish(key, errors.New("queue full or closed"))
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusOK
+}
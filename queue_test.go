@@ -0,0 +1,185 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestQueueRetries checks that failed jobs are retried up to MaxAttempts, and only reach DeadLetter after failing on
+// every attempt.
+func TestQueueRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		panics       bool
+		wantAttempts int
+		wantDead     bool
+	}{
+		{name: "succeeds", failures: 0, wantAttempts: 1},
+		{name: "succeeds on retry", failures: 2, wantAttempts: 3},
+		{name: "fails", failures: 5, wantAttempts: 3, wantDead: true},
+		{name: "panics", failures: 5, panics: true, wantAttempts: 3, wantDead: true},
+	}
+
+	for _, test := range tests {
+		var dead []string
+		q := NewQueue(QueueOptions{
+			MaxAttempts: 3,
+			Backoff:     func(int) time.Duration { return 0 },
+			DeadLetter: func(job Job, err error) {
+				dead = append(dead, job.Key)
+			},
+		})
+
+		attempts := 0
+		var result error
+		queued := q.Enqueue(Job{
+			Key: test.name,
+			Run: func(context.Context) error {
+				attempts++
+				if attempts > test.failures {
+					return nil
+				}
+				if test.panics {
+					panic("job failed")
+				}
+
+				return errors.New("job failed")
+			},
+			Done: func(err error) {
+				result = err
+			},
+		})
+		if !queued {
+			t.Fatalf("%s: Enqueue() = false, want true", test.name)
+		}
+
+		err := q.Close(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Close() failed: %v", test.name, err)
+		}
+
+		if attempts != test.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", test.name, attempts, test.wantAttempts)
+		}
+		if (len(dead) == 1) != test.wantDead || len(dead) > 1 {
+			t.Errorf("%s: dead letters %v, want dead %v", test.name, dead, test.wantDead)
+		}
+		if (result != nil) != test.wantDead {
+			t.Errorf("%s: Done() received %v, want failed %v", test.name, result, test.wantDead)
+		}
+	}
+}
+
+// TestQueueClose checks that a queue stops accepting jobs once closed, and that Close abandons the pending retries
+// when its context is done.
+func TestQueueClose(t *testing.T) {
+	q := NewQueue(QueueOptions{
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return time.Hour },
+	})
+
+	var result error
+	q.Enqueue(Job{
+		Run: func(context.Context) error { return errors.New("job failed") },
+		Done: func(err error) {
+			result = err
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := q.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Close() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if q.Enqueue(Job{Run: func(context.Context) error { return nil }}) {
+		t.Fatalf("Enqueue() on a closed queue = true, want false")
+	}
+	if err := q.Close(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("Close() of a closed queue = %v, want %v", err, ErrQueueClosed)
+	}
+
+	q.wg.Wait()
+	if result == nil {
+		t.Fatalf("Done() of an abandoned job received nil, want the last error")
+	}
+}
+
+// TestQueueJobContext checks that a job runs with the values of its Context, but isn't canceled with it.
+func TestQueueJobContext(t *testing.T) {
+	type contextKey struct{}
+
+	q := NewQueue(QueueOptions{})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "request"))
+	cancel()
+
+	var value interface{}
+	var err error
+	q.Enqueue(Job{
+		Run: func(ctx context.Context) error {
+			value = ctx.Value(contextKey{})
+			err = ctx.Err()
+			return nil
+		},
+		Context: ctx,
+	})
+	_ = q.Close(context.Background())
+
+	if value != "request" {
+		t.Errorf("Value() = %v, want %q", value, "request")
+	}
+	if err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+// TestConfirmOrderAsync checks the status answered when the callback is queued, and that the key of a job that failed
+// on every attempt is released.
+func TestConfirmOrderAsync(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	flow.setOrder("paid", &Order{FlowOrder: 1, Status: OrderStatusPayed})
+
+	c := flow.client()
+	c.IdempotencyStore = NewMemoryIdempotencyStore()
+
+	started := make(chan struct{})
+	finish := make(chan error)
+	callbacks := OrderCallbacks{
+		OnPaid: func(context.Context, *Order) error {
+			started <- struct{}{}
+			return <-finish
+		},
+	}
+
+	q := NewQueue(QueueOptions{MaxAttempts: 1})
+
+	if got := c.ConfirmOrderAsync(context.Background(), "paid", q, callbacks); got != http.StatusOK {
+		t.Fatalf("ConfirmOrderAsync() = %d, want %d", got, http.StatusOK)
+	}
+	<-started
+
+	if got := c.ConfirmOrderAsync(context.Background(), "paid", q, callbacks); got != http.StatusConflict {
+		t.Fatalf("ConfirmOrderAsync() of a queued order = %d, want %d", got, http.StatusConflict)
+	}
+
+	finish <- errors.New("job failed")
+	_ = q.Close(context.Background())
+
+	key := orderKey(&Order{FlowOrder: 1, Status: OrderStatusPayed})
+	if state, _ := c.IdempotencyStore.Claim(key, time.Hour); state != ClaimNew {
+		t.Fatalf("Claim() after the job failed = %v, want %v", state, ClaimNew)
+	}
+	_ = c.IdempotencyStore.Release(key)
+
+	if got := c.ConfirmOrderAsync(context.Background(), "paid", q, callbacks); got != http.StatusServiceUnavailable {
+		t.Fatalf("ConfirmOrderAsync() with a closed queue = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,56 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// csvHeader are the columns of the CSV written by WriteCSV.
+var csvHeader = []string{
+	"kind",
+	"commerce_order",
+	"flow_order",
+	"flow_amount",
+	"ledger_amount",
+	"flow_status",
+	"ledger_status",
+	"settlement_date",
+	"transferred",
+	"balance",
+	"detail",
+}
+
+// WriteCSV writes the discrepancies of the report as CSV, with a header row.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(csvHeader)
+	if err != nil {
+		return errors.Wrap(err, "unable to write CSV")
+	}
+
+	for _, d := range r.Discrepancies {
+		err = cw.Write([]string{
+			string(d.Kind),
+			d.CommerceOrder,
+			strconv.Itoa(d.FlowOrder),
+			strconv.FormatFloat(d.FlowAmount, 'f', -1, 64),
+			strconv.FormatFloat(d.LedgerAmount, 'f', -1, 64),
+			strconv.Itoa(d.FlowStatus),
+			strconv.Itoa(d.LedgerStatus),
+			d.SettlementDate,
+			strconv.FormatFloat(d.Transferred, 'f', -1, 64),
+			strconv.FormatFloat(d.Balance, 'f', -1, 64),
+			d.Detail,
+		})
+		if err != nil {
+			return errors.Wrap(err, "unable to write CSV")
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "unable to write CSV")
+}
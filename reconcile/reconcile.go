@@ -0,0 +1,386 @@
+// Package reconcile compares the payments received by a Flow account with the commerce's own ledger, to find the
+// orders that were lost, charged twice or recorded with the wrong amount or status, and checks the settlements
+// against the payments they transferred.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/CamiloHernandez/go-flow"
+	"github.com/pkg/errors"
+)
+
+// Kind is the kind of a Discrepancy.
+type Kind string
+
+const (
+	// MissingInLedger is a payment received by Flow that isn't in the ledger.
+	MissingInLedger Kind = "missing_in_ledger"
+
+	// MissingInFlow is an entry of the ledger without a payment in Flow.
+	MissingInFlow Kind = "missing_in_flow"
+
+	// Duplicated is a commerce order paid more than once in Flow, or recorded more than once in the ledger.
+	Duplicated Kind = "duplicated"
+
+	// AmountMismatch is a payment whose amount differs from the one in the ledger.
+	AmountMismatch Kind = "amount_mismatch"
+
+	// StatusMismatch is a payment whose status differs from the one in the ledger.
+	StatusMismatch Kind = "status_mismatch"
+
+	// SettlementMismatch is a settlement date whose transferred amount differs from the balance of the payments
+	// transferred on that date.
+	SettlementMismatch Kind = "settlement_mismatch"
+)
+
+// Entry is an order recorded in the commerce's ledger.
+type Entry struct {
+	// CommerceOrder is the commerce order of the order, as sent to Flow.
+	CommerceOrder string
+
+	// Amount is the amount charged.
+	Amount float64
+
+	// Status is the status of the order, one of the flow.OrderStatus constants.
+	Status int
+}
+
+// Ledger is the commerce's own record of its orders.
+type Ledger interface {
+	// Entries returns the entries of the orders paid between two dates, both included.
+	Entries(ctx context.Context, from, to time.Time) ([]Entry, error)
+}
+
+// Discrepancy is a difference between Flow and the ledger.
+type Discrepancy struct {
+	// Kind is the kind of discrepancy.
+	Kind Kind
+
+	// CommerceOrder is the commerce order the discrepancy is about.
+	CommerceOrder string
+
+	// FlowOrder is the Flow identifier of the payment, or 0 if it's missing in Flow.
+	FlowOrder int
+
+	// FlowAmount is the amount of the payment in Flow.
+	FlowAmount float64
+
+	// LedgerAmount is the amount of the entry in the ledger.
+	LedgerAmount float64
+
+	// FlowStatus is the status of the payment in Flow.
+	FlowStatus int
+
+	// LedgerStatus is the status of the entry in the ledger.
+	LedgerStatus int
+
+	// SettlementDate is the date of the settlement, for settlement discrepancies.
+	SettlementDate string
+
+	// Transferred is the amount transferred by the settlements of the date, for settlement discrepancies.
+	Transferred float64
+
+	// Balance is the sum of the balances of the payments transferred on the date, for settlement discrepancies.
+	Balance float64
+
+	// Detail describes the discrepancy.
+	Detail string
+}
+
+// Report is the result of a reconciliation.
+type Report struct {
+	// From is the first day reconciled.
+	From time.Time
+
+	// To is the last day reconciled.
+	To time.Time
+
+	// Payments is the number of payments received by Flow in the period.
+	Payments int
+
+	// Entries is the number of entries of the ledger in the period.
+	Entries int
+
+	// Settlements are the settlements made by Flow in the period.
+	Settlements []flow.Settlement
+
+	// Discrepancies are the differences found: the ones of the payments sorted by commerce order, followed by the ones
+	// of the settlements sorted by date.
+	Discrepancies []Discrepancy
+}
+
+// OK reports whether no discrepancies were found.
+func (r Report) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Reconciler compares the payments received by a Flow account with a Ledger.
+type Reconciler struct {
+	// Client is the client of the Flow account.
+	Client *flow.Client
+
+	// Ledger is the commerce's ledger.
+	Ledger Ledger
+
+	// PageSize is the number of payments fetched per request. It defaults to 100, the maximum allowed by Flow.
+	PageSize int
+
+	// SettlementLag is the number of days before the period whose payments are also fetched, as the settlements of the
+	// period include payments made before it. Those payments are only used to reconcile the settlements. It defaults
+	// to 7.
+	SettlementLag int
+}
+
+// Run reconciles the days between from and to, both included. The payments of the period are compared with the
+// ledger, and the settlements of the period are compared with the balance of the payments they transferred.
+func (r Reconciler) Run(ctx context.Context, from, to time.Time) (*Report, error) {
+	from = day(from)
+	to = day(to)
+	if to.Before(from) {
+		return nil, errors.New("the end of the period is before its start")
+	}
+
+	lag := r.SettlementLag
+	if lag <= 0 {
+		lag = 7
+	}
+
+	earlier, err := r.payments(ctx, from.AddDate(0, 0, -lag), from.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := r.payments(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	settlements, err := r.Client.SearchSettlementsContext(ctx, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch settlements")
+	}
+
+	entries, err := r.Ledger.Entries(ctx, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the ledger")
+	}
+
+	discrepancies := Compare(payments, entries)
+	discrepancies = append(discrepancies, CompareSettlements(append(earlier, payments...), settlements, from, to)...)
+
+	return &Report{
+		From:          from,
+		To:            to,
+		Payments:      len(payments),
+		Entries:       len(entries),
+		Settlements:   settlements,
+		Discrepancies: discrepancies,
+	}, nil
+}
+
+// payments fetches all the payments received between from and to.
+func (r Reconciler) payments(ctx context.Context, from, to time.Time) ([]flow.Order, error) {
+	pageSize := r.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	var payments []flow.Order
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		for start := 0; ; start += pageSize {
+			page, err := r.Client.GetPaymentsContext(ctx, date, start, pageSize)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to fetch the payments of %s", date.Format("2006-01-02"))
+			}
+
+			payments = append(payments, page.Data...)
+			if page.HasMore == 0 || len(page.Data) == 0 {
+				break
+			}
+		}
+	}
+
+	return payments, nil
+}
+
+// CompareSettlements finds the settlement dates between from and to whose transferred amount differs from the sum of
+// the balances of the payments with that transfer date, including the dates with transferred payments but without a
+// settlement. The discrepancies are sorted by date.
+func CompareSettlements(payments []flow.Order, settlements []flow.Settlement, from, to time.Time) []Discrepancy {
+	type dateTotals struct {
+		transferred float64
+		balance     float64
+		settlements int
+	}
+
+	totals := make(map[string]*dateTotals)
+	get := func(date string) *dateTotals {
+		if totals[date] == nil {
+			totals[date] = &dateTotals{}
+		}
+		return totals[date]
+	}
+
+	for _, settlement := range settlements {
+		t := get(dateOf(settlement.Date))
+		t.transferred += settlement.Transferred
+		t.settlements++
+	}
+
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	for _, payment := range payments {
+		date := dateOf(payment.PaymentData.TransferDate)
+		if date == "" || date < first || date > last {
+			continue
+		}
+
+		get(date).balance += float64(payment.PaymentData.Balance)
+	}
+
+	var discrepancies []Discrepancy
+	for date, t := range totals {
+		if math.Abs(t.transferred-t.balance) < 0.005 {
+			continue
+		}
+
+		d := Discrepancy{
+			Kind:           SettlementMismatch,
+			SettlementDate: date,
+			Transferred:    t.transferred,
+			Balance:        t.balance,
+			Detail: fmt.Sprintf("settlements transferred %s, payments balance %s",
+				strconv.FormatFloat(t.transferred, 'f', -1, 64), strconv.FormatFloat(t.balance, 'f', -1, 64)),
+		}
+		if t.settlements == 0 {
+			d.Detail = "payments transferred without a settlement"
+		}
+
+		discrepancies = append(discrepancies, d)
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].SettlementDate < discrepancies[j].SettlementDate
+	})
+
+	return discrepancies
+}
+
+// dateOf returns the yyyy-mm-dd date of a Flow date or datetime.
+func dateOf(value string) string {
+	if len(value) > 10 {
+		return value[:10]
+	}
+
+	return value
+}
+
+// Compare finds the discrepancies between the payments received by Flow and the entries of the ledger, matching them
+// by their commerce order. The discrepancies are sorted by commerce order.
+func Compare(payments []flow.Order, entries []Entry) []Discrepancy {
+	byOrder := make(map[string][]flow.Order)
+	for _, payment := range payments {
+		byOrder[payment.CommerceOrder] = append(byOrder[payment.CommerceOrder], payment)
+	}
+
+	byEntry := make(map[string][]Entry)
+	for _, entry := range entries {
+		byEntry[entry.CommerceOrder] = append(byEntry[entry.CommerceOrder], entry)
+	}
+
+	var discrepancies []Discrepancy
+	for commerceOrder, paid := range byOrder {
+		payment := paid[0]
+		amount, amountErr := strconv.ParseFloat(payment.Amount, 64)
+
+		d := Discrepancy{
+			CommerceOrder: commerceOrder,
+			FlowOrder:     payment.FlowOrder,
+			FlowAmount:    amount,
+			FlowStatus:    payment.Status,
+		}
+
+		recorded, ok := byEntry[commerceOrder]
+		if ok {
+			d.LedgerAmount = recorded[0].Amount
+			d.LedgerStatus = recorded[0].Status
+		}
+
+		if len(paid) > 1 {
+			d.Kind = Duplicated
+			d.Detail = fmt.Sprintf("paid %d times in Flow", len(paid))
+			discrepancies = append(discrepancies, d)
+		}
+
+		if !ok {
+			d.Kind = MissingInLedger
+			d.Detail = "paid in Flow but not in the ledger"
+			discrepancies = append(discrepancies, d)
+			continue
+		}
+
+		entry := recorded[0]
+
+		if len(recorded) > 1 {
+			d.Kind = Duplicated
+			d.Detail = fmt.Sprintf("recorded %d times in the ledger", len(recorded))
+			discrepancies = append(discrepancies, d)
+		}
+
+		if amountErr != nil || amount != entry.Amount {
+			d.Kind = AmountMismatch
+			d.Detail = fmt.Sprintf("Flow amount %q, ledger amount %s", payment.Amount,
+				strconv.FormatFloat(entry.Amount, 'f', -1, 64))
+			discrepancies = append(discrepancies, d)
+		}
+
+		if payment.Status != entry.Status {
+			d.Kind = StatusMismatch
+			d.Detail = fmt.Sprintf("Flow status %d, ledger status %d", payment.Status, entry.Status)
+			discrepancies = append(discrepancies, d)
+		}
+	}
+
+	for commerceOrder, recorded := range byEntry {
+		if _, ok := byOrder[commerceOrder]; ok {
+			continue
+		}
+
+		entry := recorded[0]
+		d := Discrepancy{
+			CommerceOrder: commerceOrder,
+			LedgerAmount:  entry.Amount,
+			LedgerStatus:  entry.Status,
+		}
+
+		if len(recorded) > 1 {
+			d.Kind = Duplicated
+			d.Detail = fmt.Sprintf("recorded %d times in the ledger", len(recorded))
+			discrepancies = append(discrepancies, d)
+		}
+
+		d.Kind = MissingInFlow
+		d.Detail = "recorded in the ledger but not paid in Flow"
+		discrepancies = append(discrepancies, d)
+	}
+
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		if discrepancies[i].CommerceOrder != discrepancies[j].CommerceOrder {
+			return discrepancies[i].CommerceOrder < discrepancies[j].CommerceOrder
+		}
+
+		return discrepancies[i].Kind < discrepancies[j].Kind
+	})
+
+	return discrepancies
+}
+
+// day truncates a time to the start of its day.
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -1,7 +1,7 @@
 package flow
 
 import (
-	"github.com/fatih/structs"
+	"context"
 	"github.com/json-iterator/go"
 	"github.com/pkg/errors"
 )
@@ -62,9 +62,17 @@ type RefundStatus struct {
 
 // CreateRefund starts a new refund request.
 func (c Client) CreateRefund(r Refund) (*RefundStatus, error) {
-	url, body := c.buildPOST("/refund/create", structs.Map(r))
+	return c.CreateRefundContext(context.Background(), r)
+}
+
+// CreateRefundContext is like CreateRefund, but the request is bound to ctx.
+func (c Client) CreateRefundContext(ctx context.Context, r Refund) (*RefundStatus, error) {
+	url, body, err := c.buildPOST("/refund/create", r)
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.post(url, body)
+	data, err := c.post(ctx, url, body)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -80,11 +88,19 @@ func (c Client) CreateRefund(r Refund) (*RefundStatus, error) {
 
 // CancelRefund cancels a refund request.
 func (c Client) CancelRefund(token string) (*RefundStatus, error) {
-	url, body := c.buildPOST("/refund/cancel", map[string]interface{}{
+	return c.CancelRefundContext(context.Background(), token)
+}
+
+// CancelRefundContext is like CancelRefund, but the request is bound to ctx.
+func (c Client) CancelRefundContext(ctx context.Context, token string) (*RefundStatus, error) {
+	url, body, err := c.buildPOST("/refund/cancel", map[string]interface{}{
 		"token": token,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.post(url, body)
+	data, err := c.post(ctx, url, body)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
@@ -100,11 +116,19 @@ func (c Client) CancelRefund(token string) (*RefundStatus, error) {
 
 // GetRefundStatus fetches the status of a refund request.
 func (c Client) GetRefundStatus(token string) (*RefundStatus, error) {
-	url := c.buildGET("/refund/getStatus", map[string]interface{}{
+	return c.GetRefundStatusContext(context.Background(), token)
+}
+
+// GetRefundStatusContext is like GetRefundStatus, but the request is bound to ctx.
+func (c Client) GetRefundStatusContext(ctx context.Context, token string) (*RefundStatus, error) {
+	url, err := c.buildGET("/refund/getStatus", map[string]interface{}{
 		"token": token,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := c.get(url)
+	data, err := c.get(ctx, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to transact with the server")
 	}
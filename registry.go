@@ -0,0 +1,174 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownTenant is returned when looking up a tenant that isn't registered.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// TenantConfig is the Flow account of a tenant.
+type TenantConfig struct {
+	// Credentials provides the keys of the account.
+	Credentials CredentialsProvider
+
+	// Production selects the production environment. By default the sandbox is used.
+	Production bool
+
+	// URL is optionally set to override the base URL of the environment.
+	URL string
+
+	// RateLimits is optionally set to limit the requests of the tenant. It defaults to limits with the same settings as
+	// the ones of the base Client, with budgets of their own.
+	RateLimits *RateLimits
+
+	// Breaker is optionally set to fail the requests of the tenant fast. It defaults to a breaker with the same settings
+	// as the one of the base Client, with a state of its own.
+	Breaker *CircuitBreaker
+}
+
+// Registry holds a Client for each tenant, for services operating several Flow accounts. It's safe for concurrent
+// use.
+type Registry struct {
+	base Client
+
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewRegistry creates an empty *Registry. The clients it creates copy the settings of base, like its Logger or Metrics,
+// and replace its credentials and URL with the ones of each tenant. As Flow handles each account separately, every
+// tenant gets its own copy of the base's RateLimits and Breaker, and the keys of the base's IdempotencyStore are
+// prefixed with the tenant, so the notifications of different accounts never collide.
+func NewRegistry(base Client) *Registry {
+	return &Registry{
+		base:    base,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Register creates the Client of a tenant, replacing the previous one if any.
+func (r *Registry) Register(tenant string, config TenantConfig) *Client {
+	c := r.base
+	c.APIKey = ""
+	c.SecretKey = ""
+	c.Credentials = config.Credentials
+
+	if c.IdempotencyStore != nil {
+		c.IdempotencyStore = tenantStore{store: c.IdempotencyStore, prefix: tenant + "/"}
+	}
+
+	c.RateLimits = config.RateLimits
+	if c.RateLimits == nil {
+		c.RateLimits = r.base.RateLimits.clone()
+	}
+
+	c.Breaker = config.Breaker
+	if c.Breaker == nil {
+		c.Breaker = r.base.Breaker.clone()
+	}
+
+	switch {
+	case config.URL != "":
+		c.URL = config.URL
+	case config.Production:
+		c.URL = ProductionURL
+	default:
+		c.URL = SandboxURL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[tenant] = &c
+	return &c
+}
+
+// Client returns the Client of a tenant, or ErrUnknownTenant if it isn't registered.
+func (r *Registry) Client(tenant string) (*Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, set := r.clients[tenant]
+	if !set {
+		return nil, ErrUnknownTenant
+	}
+
+	return c, nil
+}
+
+// tenantStore is an IdempotencyStore that prefixes the keys of a tenant before passing them to the shared store.
+type tenantStore struct {
+	store  IdempotencyStore
+	prefix string
+}
+
+// Claim claims the prefixed key.
+func (ts tenantStore) Claim(key string, lease time.Duration) (ClaimState, error) {
+	return ts.store.Claim(ts.prefix+key, lease)
+}
+
+// Complete completes the prefixed key.
+func (ts tenantStore) Complete(key string) error {
+	return ts.store.Complete(ts.prefix + key)
+}
+
+// Release releases the prefixed key.
+func (ts tenantStore) Release(key string) error {
+	return ts.store.Release(ts.prefix + key)
+}
+
+// TenantResolver identifies the tenant a request is meant for.
+type TenantResolver func(r *http.Request) string
+
+// TenantFromPath identifies the tenant by the path segment following prefix. For example, with the prefix "/flow/"
+// the tenant of "/flow/acme/confirm" is "acme".
+func TenantFromPath(prefix string) TenantResolver {
+	return func(r *http.Request) string {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return ""
+		}
+
+		return strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)[0]
+	}
+}
+
+// TenantFromQuery identifies the tenant by a query parameter.
+func TenantFromQuery(param string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(param)
+	}
+}
+
+// Handler returns a http.Handler that identifies the tenant of each request before verifying its token, and serves it
+// with the handler built for the tenant's Client, like one returned by HTTPOrderCallbacks. Requests for unknown
+// tenants are answered with 404 Not Found. The tenant is available to the callbacks through TenantFromContext.
+func (r *Registry) Handler(resolve TenantResolver, handler func(c *Client) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		tenant := resolve(rq)
+
+		c, err := r.Client(tenant)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rq = rq.WithContext(context.WithValue(rq.Context(), tenantKey{}, tenant))
+		handler(c).ServeHTTP(w, rq)
+	})
+}
+
+// tenantKey is the context key of the tenant of a request.
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant of a request served by a Registry's handler.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
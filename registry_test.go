@@ -0,0 +1,158 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTenantResolvers checks the tenant found on each request.
+func TestTenantResolvers(t *testing.T) {
+	tests := []struct {
+		name    string
+		resolve TenantResolver
+		target  string
+		want    string
+	}{
+		{name: "path", resolve: TenantFromPath("/flow/"), target: "/flow/acme/confirm", want: "acme"},
+		{name: "path without suffix", resolve: TenantFromPath("/flow/"), target: "/flow/acme", want: "acme"},
+		{name: "other path", resolve: TenantFromPath("/flow/"), target: "/other/acme/confirm", want: ""},
+		{name: "query", resolve: TenantFromQuery("tenant"), target: "/confirm?tenant=acme", want: "acme"},
+		{name: "missing query", resolve: TenantFromQuery("tenant"), target: "/confirm", want: ""},
+	}
+
+	for _, test := range tests {
+		rq := httptest.NewRequest(http.MethodPost, test.target, nil)
+		if got := test.resolve(rq); got != test.want {
+			t.Errorf("%s: tenant of %s = %q, want %q", test.name, test.target, got, test.want)
+		}
+	}
+}
+
+// TestRegistryHandler checks that each callback is served with the client of its tenant, and that the callbacks of
+// unknown tenants are rejected.
+func TestRegistryHandler(t *testing.T) {
+	flow := newFakeFlow()
+	defer flow.Close()
+
+	flow.setOrder("paid", &Order{FlowOrder: 1, Status: OrderStatusPayed})
+
+	registry := NewRegistry(Client{})
+	registry.Register("acme", TenantConfig{
+		Credentials: StaticCredentials{APIKey: "XXXX-XXXX-XXXX", SecretKey: "YYYY-YYYY-YYYY"},
+		URL:         flow.URL,
+	})
+
+	var tenant string
+	handler := registry.Handler(TenantFromPath("/flow/"), func(c *Client) http.Handler {
+		return c.HTTPOrderCallbacks(OrderCallbacks{
+			OnPaid: func(ctx context.Context, order *Order) error {
+				tenant, _ = TenantFromContext(ctx)
+				return nil
+			},
+		})
+	})
+
+	tests := []struct {
+		target     string
+		want       int
+		wantTenant string
+	}{
+		{target: "/flow/acme/confirm", want: http.StatusOK, wantTenant: "acme"},
+		{target: "/flow/other/confirm", want: http.StatusNotFound},
+		{target: "/confirm", want: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		tenant = ""
+
+		rq := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader("token=paid"))
+		rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, rq)
+
+		if rec.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.target, rec.Code, test.want)
+		}
+		if tenant != test.wantTenant {
+			t.Errorf("%s: tenant = %q, want %q", test.target, tenant, test.wantTenant)
+		}
+	}
+}
+
+// TestRegistryURL checks the URL of the client of each tenant.
+func TestRegistryURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TenantConfig
+		want   string
+	}{
+		{name: "sandbox", config: TenantConfig{}, want: SandboxURL},
+		{name: "production", config: TenantConfig{Production: true}, want: ProductionURL},
+		{name: "custom", config: TenantConfig{Production: true, URL: "http://flow.test"}, want: "http://flow.test"},
+	}
+
+	registry := NewRegistry(*NewClient("", ""))
+	for _, test := range tests {
+		registry.Register(test.name, test.config)
+
+		c, err := registry.Client(test.name)
+		if err != nil {
+			t.Fatalf("%s: Client() failed: %v", test.name, err)
+		}
+		if c.URL != test.want {
+			t.Errorf("%s: URL = %q, want %q", test.name, c.URL, test.want)
+		}
+	}
+
+	if _, err := registry.Client("unknown"); err != ErrUnknownTenant {
+		t.Fatalf("Client() of an unknown tenant = %v, want %v", err, ErrUnknownTenant)
+	}
+}
+
+// TestRegistryTenantsIsolated checks that tenants don't share idempotency keys, rate limit budgets or breaker
+// states, unless they're configured to.
+func TestRegistryTenantsIsolated(t *testing.T) {
+	shared := NewCircuitBreaker(BreakerSettings{})
+	registry := NewRegistry(Client{
+		IdempotencyStore: NewMemoryIdempotencyStore(),
+		RateLimits:       &RateLimits{Default: NewLimiter(0, 1, 1)},
+		Breaker:          NewCircuitBreaker(BreakerSettings{MinRequests: 1, OpenDuration: time.Hour}),
+	})
+
+	acme := registry.Register("acme", TenantConfig{})
+	other := registry.Register("other", TenantConfig{})
+	custom := registry.Register("custom", TenantConfig{Breaker: shared})
+
+	if state, _ := acme.claim("order-1-2"); state != ClaimNew {
+		t.Fatalf("claim() = %v, want %v", state, ClaimNew)
+	}
+	if state, _ := other.claim("order-1-2"); state != ClaimNew {
+		t.Errorf("claim() of a key claimed by another tenant = %v, want %v", state, ClaimNew)
+	}
+	if state, _ := acme.claim("order-1-2"); state != ClaimInFlight {
+		t.Errorf("claim() of a key claimed by the same tenant = %v, want %v", state, ClaimInFlight)
+	}
+
+	_, err := acme.RateLimits.wait(context.Background(), "/payment/getStatus")
+	if err != nil {
+		t.Fatalf("wait() failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := other.RateLimits.wait(ctx, "/payment/getStatus"); err != nil {
+		t.Errorf("wait() with the budget of another tenant spent = %v, want nil", err)
+	}
+
+	generation, _ := acme.Breaker.allow()
+	acme.Breaker.record(generation, boolPtr(true))
+	if state := other.Breaker.State(); state != BreakerClosed {
+		t.Errorf("State() with the breaker of another tenant open = %v, want %v", state, BreakerClosed)
+	}
+	if custom.Breaker != shared {
+		t.Errorf("Breaker isn't the one of the tenant's config")
+	}
+}
@@ -1,15 +1,17 @@
 package flow
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/json-iterator/go"
 	"github.com/pkg/errors"
@@ -22,115 +24,255 @@ type requestError struct {
 
 	// Code is the error's code.
 	Code    int    `json:"code"`
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+}
+
+// Error returns the error detail sent by Flow.
+func (e *requestError) Error() string {
+	return e.Message
 }
 
 // buildPOST parses an URL and prepares the data body. It automatically adds the verification hash and the API Key.
-func (c Client) buildPOST(endpoint string, data map[string]interface{}) (*url.URL, string) {
-	data["apiKey"] = c.APIKey
+// The data is encoded with EncodeParams.
+func (c Client) buildPOST(endpoint string, data interface{}) (*url.URL, string, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, "", err
+	}
+
+	params := EncodeParams(data).Set("apiKey", creds.APIKey)
 
 	rqURL, _ := url.Parse(c.URL)
 	rqURL.Path = path.Join(rqURL.Path, endpoint)
 
-	var form = url.Values{}
-	form.Set("s", c.signData(data))
-	for key, value := range data {
-		form.Set(key, fmt.Sprintf("%v", value))
-	}
-
-	return rqURL, form.Encode()
+	return rqURL, params.Encode() + "&s=" + signParams(params, creds.SecretKey), nil
 }
 
-// buildPOST parses an URL and adds the data as query parameters. It automatically adds the verification hash and the API Key.
-func (c Client) buildGET(endpoint string, data map[string]interface{}) *url.URL {
-	data["apiKey"] = c.APIKey
+// buildGET parses an URL and adds the data as query parameters. It automatically adds the verification hash and the
+// API Key. The data is encoded with EncodeParams.
+func (c Client) buildGET(endpoint string, data interface{}) (*url.URL, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	params := EncodeParams(data).Set("apiKey", creds.APIKey)
 
 	rqURL, _ := url.Parse(c.URL)
 	rqURL.Path = path.Join(rqURL.Path, endpoint)
+	rqURL.RawQuery = params.Encode() + "&s=" + signParams(params, creds.SecretKey)
+
+	return rqURL, nil
+}
+
+// do executes a request, retrying it following the client's Retry policy.
+func (c Client) do(ctx context.Context, method string, rqURL *url.URL, body string) (data []byte, err error) {
+	attempts := 1
+	if c.Retry != nil && method == http.MethodGet {
+		attempts = c.Retry.attempts()
+	}
+
+	for attempt := 1; ; attempt++ {
+		data, err = c.doOnce(ctx, method, rqURL, body)
+		if err == nil || attempt >= attempts || !IsAmbiguous(err) {
+			return data, err
+		}
 
-	query := rqURL.Query()
-	query.Set("s", c.signData(data))
+		select {
+		case <-time.After(c.Retry.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
 
-	for key, value := range data {
-		query.Set(key, fmt.Sprintf("%v", value))
+// doOnce executes a request once the client's Breaker and RateLimits allow it, reporting it to the client's Logger,
+// Metrics and Tracer.
+func (c Client) doOnce(ctx context.Context, method string, rqURL *url.URL, body string) (data []byte, err error) {
+	info := requestInfo{
+		Method:   method,
+		Endpoint: c.endpoint(rqURL),
+		URL:      rqURL,
+		Body:     body,
 	}
 
-	rqURL.RawQuery = query.Encode()
+	// The breaker is checked first, so requests fail fast while it's open instead of waiting for the rate limits.
+	var generation uint64
+	if c.Breaker != nil {
+		var allowed bool
+		generation, allowed = c.Breaker.allow()
+		if !allowed {
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	release, err := c.RateLimits.wait(ctx, info.Endpoint)
+	if err != nil {
+		if c.Breaker != nil {
+			c.Breaker.record(generation, nil)
+		}
+
+		return nil, errors.Wrap(err, "rate limit wait canceled")
+	}
+	defer release()
+
+	ctx, span := c.startSpan(ctx, "flow "+method+" "+info.Endpoint)
+	defer span.End()
+
+	start := time.Now()
+	info.StatusCode, info.Response, err = c.send(ctx, method, rqURL, body)
+	info.Duration = time.Since(start)
+	info.Err = err
+
+	if c.Breaker != nil {
+		c.Breaker.record(generation, breakerResult(ctx, info))
+	}
+
+	c.logRequest(info)
+	c.observeRequest(info)
+	traceRequest(span, info)
+
+	if err != nil {
+		return nil, err
+	}
 
-	return rqURL
+	return info.Response, nil
 }
 
-// do executes a request.
-func (c Client) do(method string, rqURL *url.URL, body string) (data []byte, err error) {
+// send executes a request and returns the response's status code and body. The body is returned even if the request
+// failed with an error response.
+func (c Client) send(ctx context.Context, method string, rqURL *url.URL, body string) (status int, data []byte,
+	err error) {
 	var rq *http.Request
 	if body != "" {
-		rq, err = http.NewRequest(method, rqURL.String(), strings.NewReader(body))
+		rq, err = http.NewRequestWithContext(ctx, method, rqURL.String(), strings.NewReader(body))
 	} else {
-		rq, err = http.NewRequest(method, rqURL.String(), nil)
+		rq, err = http.NewRequestWithContext(ctx, method, rqURL.String(), nil)
 	}
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
 	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.Tracer != nil {
+		c.Tracer.Inject(ctx, rq.Header)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 
-	httpClient := http.Client{}
 	resp, err := httpClient.Do(rq)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
+	defer resp.Body.Close()
 
 	data, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return resp.StatusCode, nil, err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		var rqError requestError
 		err = jsoniter.Unmarshal(data, &rqError)
 		if err != nil {
-			return nil, fmt.Errorf("server error: http error %s (%d)", resp.Status, resp.StatusCode)
+			return resp.StatusCode, data, fmt.Errorf("server error: http error %s (%d)", resp.Status, resp.StatusCode)
 		}
 
-		return nil, errors.New(rqError.Message)
+		rqError.StatusCode = resp.StatusCode
+		return resp.StatusCode, data, &rqError
 	}
 
-	return data, nil
+	return resp.StatusCode, data, nil
+}
+
+// endpoint returns the path of a request URL relative to the client's base URL.
+func (c Client) endpoint(rqURL *url.URL) string {
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return rqURL.Path
+	}
+
+	return "/" + strings.TrimLeft(strings.TrimPrefix(rqURL.Path, base.Path), "/")
 }
 
 // get is short hand for do with "GET" as the method.
-func (c Client) get(rqURL *url.URL) (data []byte, err error) {
-	return c.do("GET", rqURL, "")
+func (c Client) get(ctx context.Context, rqURL *url.URL) (data []byte, err error) {
+	return c.do(ctx, "GET", rqURL, "")
 }
 
 // get is short hand for do with "POST" as the method.
-func (c Client) post(rqURL *url.URL, body string) (data []byte, err error) {
-	return c.do("POST", rqURL, body)
+func (c Client) post(ctx context.Context, rqURL *url.URL, body string) (data []byte, err error) {
+	return c.do(ctx, "POST", rqURL, body)
 }
 
-// signData creates a verification hashed using the client's secret key.
-func (c Client) signData(data map[string]interface{}) string {
-	// https://www.flow.cl/docs/api.html#section/Introduccion/Como-firmar-con-su-SecretKey
-	ordered := sortMapKeys(data)
+// Sign returns the signature of params made with the client's secret key, following Flow's signing scheme: the
+// parameters are encoded with EncodeParams, concatenated as name followed by value, and signed with HMAC-SHA256. The
+// result is hex encoded. The "s" parameter, which carries the signature, is never signed. It fails if the client's
+// credentials can't be loaded.
+func (c Client) Sign(params map[string]interface{}) (string, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return "", err
+	}
 
-	var hashValues string
-	for _, key := range ordered {
-		hashValues += fmt.Sprintf("%v%v", key, data[key])
+	return signParams(unsignedParams(params), creds.SecretKey), nil
+}
+
+// Verify reports whether signature is a valid signature of params made with the client's secret key, or with one of
+// its previous secret keys. The signatures are compared in constant time.
+func (c Client) Verify(params map[string]interface{}, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	creds, err := c.credentials()
+	if err != nil {
+		return false
+	}
+
+	encoded := unsignedParams(params)
+	for _, secret := range append([]string{creds.SecretKey}, creds.PreviousSecretKeys...) {
+		want, _ := hex.DecodeString(signParams(encoded, secret))
+		if hmac.Equal(got, want) {
+			return true
+		}
 	}
 
-	return hmac256Sign(hashValues, c.SecretKey)
+	return false
 }
 
-// sortMapKeys creates an alphabetically sorted slice of keys.
-func sortMapKeys(m map[string]interface{}) []string {
-	mk := make([]string, len(m))
-	i := 0
-	for k := range m {
-		mk[i] = k
-		i++
+// VerifyValues reports whether the form or query values carry a valid signature in their "s" parameter.
+func (c Client) VerifyValues(values url.Values) bool {
+	params := make(map[string]interface{}, len(values))
+	for key := range values {
+		params[key] = values.Get(key)
 	}
-	sort.Strings(mk)
 
-	return mk
+	return c.Verify(params, values.Get("s"))
+}
+
+// unsignedParams encodes params without the "s" parameter.
+func unsignedParams(params map[string]interface{}) Params {
+	data := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if key != "s" {
+			data[key] = value
+		}
+	}
+
+	return EncodeParams(data)
+}
+
+// signParams creates a verification hashed using the secret key.
+func signParams(params Params, secret string) string {
+	// https://www.flow.cl/docs/api.html#section/Introduccion/Como-firmar-con-su-SecretKey
+	return hmac256Sign(params.signingString(), secret)
 }
 
 // hmac256Sign signs a string with a key using HMAC with SHA256.
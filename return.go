@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidToken is reported when a request sent by Flow doesn't carry a valid token.
+var ErrInvalidToken = errors.New("missing or invalid token")
+
+// ReturnOutcome classifies an order for the page shown to the payer on the urlReturn.
+type ReturnOutcome int
+
+const (
+	// ReturnSuccess is the outcome of payed orders.
+	ReturnSuccess ReturnOutcome = iota
+
+	// ReturnPending is the outcome of orders still awaiting payment.
+	ReturnPending
+
+	// ReturnFailure is the outcome of rejected or canceled orders, and of tokens that couldn't be resolved.
+	ReturnFailure
+)
+
+// String returns the name of the template used to render the outcome: "success", "pending" or "failure".
+func (ro ReturnOutcome) String() string {
+	switch ro {
+	case ReturnSuccess:
+		return "success"
+	case ReturnPending:
+		return "pending"
+	}
+
+	return "failure"
+}
+
+// ReturnView is the data available when rendering the page shown to the payer.
+type ReturnView struct {
+	// Outcome is the classification of the order.
+	Outcome ReturnOutcome
+
+	// Order is the order being returned from. It's nil if the token couldn't be resolved.
+	Order *Order
+
+	// Err is the reason the token couldn't be resolved, if any.
+	Err error
+}
+
+// ReturnHandler is a http.Handler for the urlReturn of an order. It reads the token posted by Flow, fetches the order
+// and renders the view matching its outcome.
+//
+// Flow might send the payer back before the confirmation of a payment is processed, so the order can still be
+// awaiting payment when it's fetched. If Wait is set, pending orders are fetched again until they reach a final status
+// or Wait runs out.
+type ReturnHandler struct {
+	// Client is used to fetch the orders.
+	Client *Client
+
+	// Template renders the views. It must define a template for each outcome, named "success", "pending" and
+	// "failure", which are executed with a ReturnView.
+	Template *template.Template
+
+	// Render is optionally set to render the views instead of Template. Either Render or Template must be set.
+	Render func(w http.ResponseWriter, r *http.Request, view ReturnView)
+
+	// Wait is the maximum time spent waiting for a pending order to reach a final status.
+	Wait time.Duration
+
+	// PollInterval is the time between fetches of a pending order. It defaults to one second.
+	PollInterval time.Duration
+}
+
+// ServeHTTP resolves the token of the request and renders the view of its order. It answers with 500 Internal Server
+// Error if neither Render nor Template are set.
+func (h *ReturnHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Render == nil && h.Template == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	view := h.resolve(r)
+
+	if h.Render != nil {
+		h.Render(w, r, view)
+		return
+	}
+
+	var page bytes.Buffer
+	err := h.Template.ExecuteTemplate(&page, view.Outcome.String(), view)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = page.WriteTo(w)
+}
+
+// resolve fetches the order of the request, waiting for it while it's pending.
+func (h *ReturnHandler) resolve(r *http.Request) ReturnView {
+	token := callbackToken(r)
+	if token == "" {
+		return ReturnView{Outcome: ReturnFailure, Err: ErrInvalidToken}
+	}
+
+	interval := h.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	deadline := time.Now().Add(h.Wait)
+
+	for {
+		order, err := h.Client.GetOrderContext(r.Context(), token)
+		if err != nil {
+			return ReturnView{Outcome: ReturnFailure, Err: err}
+		}
+
+		view := ReturnView{Outcome: returnOutcome(order), Order: order}
+		if view.Outcome != ReturnPending || time.Now().Add(interval).After(deadline) {
+			return view
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-r.Context().Done():
+			return view
+		}
+	}
+}
+
+// returnOutcome classifies an order.
+func returnOutcome(order *Order) ReturnOutcome {
+	switch order.Status {
+	case OrderStatusPayed:
+		return ReturnSuccess
+	case OrderStatusAwaitingPayment:
+		return ReturnPending
+	}
+
+	return ReturnFailure
+}
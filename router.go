@@ -0,0 +1,130 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// RegisterHandlerFunc processes the result of a customer's card registration. If it returns an error, the router
+// answers with an error status so Flow retries the delivery.
+type RegisterHandlerFunc func(ctx context.Context, status *RegisterStatus) error
+
+// InvoiceHandlerFunc processes an invoice notified by Flow. If it returns an error, the router answers with an error
+// status so Flow retries the delivery.
+type InvoiceHandlerFunc func(ctx context.Context, invoice *Invoice) error
+
+// Router is a http.Handler that receives every kind of notification sent by Flow, each one on its own path, so a
+// single handler can be mounted instead of one per notification URL. Each notification is resolved against the Flow
+// endpoint matching its kind before being dispatched, and deduplicated with the Client's IdempotencyStore.
+type Router struct {
+	client *Client
+	mux    *http.ServeMux
+}
+
+// NewRouter creates an empty *Router that resolves the notifications with the given client.
+func NewRouter(c *Client) *Router {
+	return &Router{
+		client: c,
+		mux:    http.NewServeMux(),
+	}
+}
+
+// ServeHTTP dispatches the notification to the handler registered for the request's path.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// HandleOrders registers the callbacks for the payment confirmations sent to the urlConfirmation of an order.
+func (rt *Router) HandleOrders(pattern string, callbacks OrderCallbacks) {
+	rt.mux.Handle(pattern, rt.client.HTTPOrderCallbacks(callbacks))
+}
+
+// HandleRefunds registers the callbacks for the refund confirmations sent to the urlCallBack of a refund.
+func (rt *Router) HandleRefunds(pattern string, callbacks RefundCallbacks) {
+	rt.mux.Handle(pattern, rt.client.HTTPRefundCallbacks(callbacks))
+}
+
+// HandleSubscriptionPayments registers the callbacks for the payments of a subscription, sent to the urlCallback of
+// its plan. Flow notifies them with a payment token, so they are resolved like the order confirmations.
+func (rt *Router) HandleSubscriptionPayments(pattern string, callbacks OrderCallbacks) {
+	rt.mux.Handle(pattern, rt.client.HTTPOrderCallbacks(callbacks))
+}
+
+// HandleCustomerRegisters registers the function called with the result of a customer's card registration, sent to
+// the url_return of the registration. The token is resolved with GetRegisterStatus.
+func (rt *Router) HandleCustomerRegisters(pattern string, fn RegisterHandlerFunc) {
+	rt.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(rt.confirmRegister(r.Context(), callbackToken(r), fn))
+	})
+}
+
+// HandleInvoices registers the function called with the invoices notified by Flow. The notification must carry the
+// invoiceId parameter, which is resolved with GetInvoice.
+func (rt *Router) HandleInvoices(pattern string, fn InvoiceHandlerFunc) {
+	rt.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		invoiceID := 0
+		if r.ParseForm() == nil {
+			invoiceID, _ = strconv.Atoi(r.Form.Get("invoiceId"))
+		}
+
+		w.WriteHeader(rt.confirmInvoice(r.Context(), invoiceID, fn))
+	})
+}
+
+// confirmRegister processes a card registration token and returns the status to answer with.
+func (rt *Router) confirmRegister(ctx context.Context, token string, fn RegisterHandlerFunc) int {
+	ctx, span := rt.client.startSpan(ctx, "flow register callback")
+	defer span.End()
+
+	if token == "" {
+		rt.client.observeCallback("register", outcomeInvalidToken)
+		span.SetError(ErrInvalidToken)
+		return http.StatusBadRequest
+	}
+
+	status, err := rt.client.GetRegisterStatusContext(ctx, token)
+	if err != nil {
+		rt.client.observeCallback("register", resolveOutcome(err))
+		span.SetError(err)
+		return http.StatusInternalServerError
+	}
+
+	key := fmt.Sprintf("register-%s-%s", status.CustomerID, status.Status)
+	code := rt.client.dispatch(key, func() error { return fn(ctx, status) })
+
+	rt.client.observeCallback("register", callbackOutcome(code, registerStatusName(status.Status)))
+	span.SetAttribute("flow.customerId", status.CustomerID)
+	span.SetAttribute("flow.status", registerStatusName(status.Status))
+	span.SetAttribute("http.status_code", code)
+	return code
+}
+
+// confirmInvoice processes an invoice notification and returns the status to answer with.
+func (rt *Router) confirmInvoice(ctx context.Context, invoiceID int, fn InvoiceHandlerFunc) int {
+	ctx, span := rt.client.startSpan(ctx, "flow invoice callback")
+	defer span.End()
+
+	if invoiceID <= 0 {
+		rt.client.observeCallback("invoice", outcomeInvalidToken)
+		span.SetError(ErrInvalidToken)
+		return http.StatusBadRequest
+	}
+
+	invoice, err := rt.client.GetInvoiceContext(ctx, invoiceID)
+	if err != nil {
+		rt.client.observeCallback("invoice", resolveOutcome(err))
+		span.SetError(err)
+		return http.StatusInternalServerError
+	}
+
+	key := fmt.Sprintf("invoice-%d-%d", invoice.ID, invoice.Status)
+	code := rt.client.dispatch(key, func() error { return fn(ctx, invoice) })
+
+	rt.client.observeCallback("invoice", callbackOutcome(code, invoiceStatusName(invoice.Status)))
+	span.SetAttribute("flow.invoiceId", invoice.ID)
+	span.SetAttribute("flow.status", invoiceStatusName(invoice.Status))
+	span.SetAttribute("http.status_code", code)
+	return code
+}
@@ -0,0 +1,71 @@
+package flow
+
+import (
+	"context"
+	"time"
+
+	"github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// Settlement is a transfer of the balance of the account made by Flow to the commerce.
+type Settlement struct {
+	// ID is the Flow identifier of the settlement.
+	ID int `json:"id"`
+
+	// Date is the date of the settlement. It follows the format yyyy-mm-dd
+	Date string `json:"date"`
+
+	// TaxID is the RUT of the commerce.
+	TaxID string `json:"taxId"`
+
+	// Name is the name of the commerce.
+	Name string `json:"name"`
+
+	// Email is the email of the commerce.
+	Email string `json:"email"`
+
+	// Currency is the currency of the settlement.
+	Currency string `json:"currency"`
+
+	// InitialBalance is the balance of the account before the settlement.
+	InitialBalance float64 `json:"initialBalance"`
+
+	// FinalBalance is the balance of the account after the settlement.
+	FinalBalance float64 `json:"finalBalance"`
+
+	// Transferred is the amount of money transferred to the commerce.
+	Transferred float64 `json:"transferred"`
+
+	// Billed is the amount of money billed by Flow.
+	Billed float64 `json:"billed"`
+}
+
+// SearchSettlements fetches the settlements made between two dates, both included.
+func (c Client) SearchSettlements(start, end time.Time) ([]Settlement, error) {
+	return c.SearchSettlementsContext(context.Background(), start, end)
+}
+
+// SearchSettlementsContext is like SearchSettlements, but the request is bound to ctx.
+func (c Client) SearchSettlementsContext(ctx context.Context, start, end time.Time) ([]Settlement, error) {
+	url, err := c.buildGET("/settlement/search", map[string]interface{}{
+		"startDate": start.Format("2006-01-02"),
+		"endDate":   end.Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to transact with the server")
+	}
+
+	var settlements []Settlement
+	err = jsoniter.Unmarshal(data, &settlements)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse response")
+	}
+
+	return settlements, nil
+}
@@ -0,0 +1,77 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/json-iterator/go"
+)
+
+// Tracer starts the spans of the requests made by a Client and of the callbacks it processes, so they can be reported
+// to a tracing system. The span of a callback is the parent of the span of the request verifying its token.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, and returns a context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+
+	// Inject adds the trace context in ctx to the headers of a request sent to Flow.
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span is a traced operation started by a Tracer.
+type Span interface {
+	// SetAttribute annotates the span. The value is a string, an int or a bool.
+	SetAttribute(key string, value interface{})
+
+	// SetError marks the span as failed.
+	SetError(err error)
+
+	// End finishes the span.
+	End()
+}
+
+// tracedIDs are the parameters and response fields identifying an order that are added to the spans.
+var tracedIDs = []string{"flowOrder", "commerceOrder"}
+
+// noopSpan is the Span used when the Client has no Tracer.
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) SetError(error)                   {}
+func (noopSpan) End()                             {}
+
+// startSpan starts a span with the client's Tracer, if any.
+func (c Client) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	return c.Tracer.Start(ctx, name)
+}
+
+// traceRequest annotates the span of a request with its result.
+func traceRequest(span Span, info requestInfo) {
+	span.SetAttribute("http.method", info.Method)
+	span.SetAttribute("flow.endpoint", info.Endpoint)
+	span.SetAttribute("http.status_code", info.StatusCode)
+
+	params, _ := url.ParseQuery(info.URL.RawQuery)
+	body, _ := url.ParseQuery(info.Body)
+	for _, key := range tracedIDs {
+		value := params.Get(key)
+		if value == "" {
+			value = body.Get(key)
+		}
+		if value == "" && len(info.Response) > 0 {
+			value = jsoniter.Get(info.Response, key).ToString()
+		}
+
+		if value != "" && value != "0" {
+			span.SetAttribute("flow."+key, value)
+		}
+	}
+
+	if info.Err != nil {
+		span.SetError(info.Err)
+	}
+}